@@ -0,0 +1,164 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// An assertion failed. Kept as its own type so metrics can tell it apart from other errors.
+// +kubebuilder:object:generate=false
+type AssertionError struct {
+	Assertion string
+	Message   string
+}
+
+func (e *AssertionError) Error() string {
+	return fmt.Sprintf("assertion '%s' failed: %s", e.Assertion, e.Message)
+}
+
+func (a *Assertion) operator() AssertionOperator {
+	if a.Operator == "" {
+		return AssertionOperatorEquals
+	}
+	return a.Operator
+}
+
+// The name used for logs and metrics
+func (a *Assertion) DisplayName() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return strings.Join(strings.Fields(fmt.Sprintf("%s %s %s", a.From, a.JsonPath, a.operator())), " ")
+}
+
+// Extract the value being asserted on. `found` is false if the value does not exist in the response.
+func (a *Assertion) actualValue(ctx context.Context, result *RequestResult) (value string, found bool, err error) {
+	if a.From == FromTypeResponseSize {
+		body := readBodyAndReset(result.Response)
+		return strconv.Itoa(len(body)), true, nil
+	}
+
+	// Reuse the variable parsing so assertions and variables agree on paths
+	v := &Variable{
+		From:           a.From,
+		JsonPath:       a.JsonPath,
+		ExpressionType: a.ExpressionType,
+	}
+	err = v.ParseFromResult(ctx, result)
+	if err != nil {
+		return "", false, err
+	}
+	return v.Value, true, nil
+}
+
+func compareNumbers(actual, expected string, compare func(a, b float64) bool) (bool, error) {
+	a, err := strconv.ParseFloat(actual, 64)
+	if err != nil {
+		return false, fmt.Errorf("actual value is not a number: %s", actual)
+	}
+	b, err := strconv.ParseFloat(expected, 64)
+	if err != nil {
+		return false, fmt.Errorf("expected value is not a number: %s", expected)
+	}
+	return compare(a, b), nil
+}
+
+// Check the response against the assertion. Returns an *AssertionError on failure.
+func (a *Assertion) Evaluate(resp *http.Response) error {
+	return a.EvaluateResult(context.Background(), &RequestResult{Response: resp})
+}
+
+// Same as Evaluate, but also supports response_time
+func (a *Assertion) EvaluateResult(ctx context.Context, result *RequestResult) error {
+	fail := func(format string, args ...interface{}) error {
+		return &AssertionError{
+			Assertion: a.DisplayName(),
+			Message:   fmt.Sprintf(format, args...),
+		}
+	}
+
+	if result == nil || result.Response == nil {
+		return fail("got nil response object")
+	}
+
+	operator := a.operator()
+	actual, found, parseErr := a.actualValue(ctx, result)
+
+	switch operator {
+	case AssertionOperatorExists:
+		if !found {
+			return fail("value does not exist: %s", parseErr)
+		}
+		return nil
+	case AssertionOperatorNotExists:
+		if found {
+			return fail("value exists: %s", actual)
+		}
+		// A response that can't be parsed, like an HTML error page, does not prove the value is missing
+		var notFoundErr *NotFoundError
+		if !errors.As(parseErr, &notFoundErr) {
+			return fail("could not extract value: %s", parseErr)
+		}
+		return nil
+	}
+
+	if !found {
+		return fail("could not extract value: %s", parseErr)
+	}
+
+	var ok bool
+	var err error
+	switch operator {
+	case AssertionOperatorEquals:
+		ok = actual == a.Value
+	case AssertionOperatorNotEquals:
+		ok = actual != a.Value
+	case AssertionOperatorContains:
+		ok = strings.Contains(actual, a.Value)
+	case AssertionOperatorRegex:
+		ok, err = regexp.MatchString(a.Value, actual)
+	case AssertionOperatorLessThan:
+		ok, err = compareNumbers(actual, a.Value, func(a, b float64) bool { return a < b })
+	case AssertionOperatorGreaterThan:
+		ok, err = compareNumbers(actual, a.Value, func(a, b float64) bool { return a > b })
+	default:
+		return fail("not a known assertion operator: %s", operator)
+	}
+
+	if err != nil {
+		return fail("%s", err)
+	}
+	if !ok {
+		return fail("got '%s', expected %s '%s'", actual, operator, a.Value)
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestAssertion_Evaluate(t *testing.T) {
+	tests := []struct {
+		TestName  string
+		Assertion Assertion
+		Resp      *http.Response
+		ExpectErr bool
+	}{
+		// JSON
+		{
+			"json-equals",
+			Assertion{
+				From:     FromTypeBodyJson,
+				JsonPath: "/status",
+				Value:    "ok",
+			},
+			&http.Response{
+				Body: newReaderCloser(`{"status": "ok"}`),
+			},
+			false,
+		},
+		{
+			"json-not-equal",
+			Assertion{
+				From:     FromTypeBodyJson,
+				JsonPath: "/status",
+				Value:    "ok",
+			},
+			&http.Response{
+				Body: newReaderCloser(`{"status": "error"}`),
+			},
+			true,
+		},
+		{
+			"json-regex",
+			Assertion{
+				From:     FromTypeBodyJson,
+				JsonPath: "/items/0/id",
+				Operator: AssertionOperatorRegex,
+				Value:    "^[0-9]+$",
+			},
+			&http.Response{
+				Body: newReaderCloser(`{"items": [{"id": 1234}]}`),
+			},
+			false,
+		},
+		{
+			"json-exists",
+			Assertion{
+				From:     FromTypeBodyJson,
+				JsonPath: "/user/id",
+				Operator: AssertionOperatorExists,
+			},
+			&http.Response{
+				Body: newReaderCloser(`{"user": {"id": "abc"}}`),
+			},
+			false,
+		},
+		{
+			"json-exists-missing",
+			Assertion{
+				From:     FromTypeBodyJson,
+				JsonPath: "/user/id",
+				Operator: AssertionOperatorExists,
+			},
+			&http.Response{
+				Body: newReaderCloser(`{"user": {}}`),
+			},
+			true,
+		},
+		{
+			"json-not-exists",
+			Assertion{
+				From:     FromTypeBodyJson,
+				JsonPath: "/error",
+				Operator: AssertionOperatorNotExists,
+			},
+			&http.Response{
+				Body: newReaderCloser(`{"status": "ok"}`),
+			},
+			false,
+		},
+		{
+			"json-not-exists-html-error-page",
+			Assertion{
+				From:     FromTypeBodyJson,
+				JsonPath: "/error",
+				Operator: AssertionOperatorNotExists,
+			},
+			&http.Response{
+				Body: newReaderCloser(`<html><body>502 Bad Gateway</body></html>`),
+			},
+			true,
+		},
+		{
+			"jsonpath-not-exists",
+			Assertion{
+				From:           FromTypeBodyJson,
+				JsonPath:       "$.error",
+				ExpressionType: ExpressionTypeJsonPath,
+				Operator:       AssertionOperatorNotExists,
+			},
+			&http.Response{
+				Body: newReaderCloser(`{"status": "ok"}`),
+			},
+			false,
+		},
+		{
+			"jsonpath-not-exists-html-error-page",
+			Assertion{
+				From:           FromTypeBodyJson,
+				JsonPath:       "$.error",
+				ExpressionType: ExpressionTypeJsonPath,
+				Operator:       AssertionOperatorNotExists,
+			},
+			&http.Response{
+				Body: newReaderCloser(`<html><body>502 Bad Gateway</body></html>`),
+			},
+			true,
+		},
+		// YAML
+		{
+			"yaml-equals",
+			Assertion{
+				From:     FromTypeBodyYaml,
+				JsonPath: "/myVal/1",
+				Value:    "asdf",
+			},
+			&http.Response{
+				Body: newReaderCloser(`myVal: ["qwerty", "asdf"]`),
+			},
+			false,
+		},
+		// raw body
+		{
+			"raw-contains",
+			Assertion{
+				From:     FromTypeBodyRaw,
+				Operator: AssertionOperatorContains,
+				Value:    "healthy",
+			},
+			&http.Response{
+				Body: newReaderCloser(`<p>service is healthy</p>`),
+			},
+			false,
+		},
+		{
+			"raw-regex-no-match",
+			Assertion{
+				From:     FromTypeBodyRaw,
+				Operator: AssertionOperatorRegex,
+				Value:    "^OK$",
+			},
+			&http.Response{
+				Body: newReaderCloser(`NOT OK`),
+			},
+			true,
+		},
+		{
+			"raw-invalid-regex",
+			Assertion{
+				From:     FromTypeBodyRaw,
+				Operator: AssertionOperatorRegex,
+				Value:    "[",
+			},
+			&http.Response{
+				Body: newReaderCloser(`[`),
+			},
+			true,
+		},
+		// headers
+		{
+			"header-equals",
+			Assertion{
+				From:     FromTypeHeaders,
+				JsonPath: "/Content-Type",
+				Value:    "application/json",
+			},
+			&http.Response{
+				Header: http.Header{
+					"Content-Type": []string{"application/json"},
+				},
+			},
+			false,
+		},
+		{
+			"header-missing",
+			Assertion{
+				From:     FromTypeHeaders,
+				JsonPath: "/Content-Type",
+				Value:    "application/json",
+			},
+			&http.Response{
+				Header: http.Header{},
+			},
+			true,
+		},
+		// size
+		{
+			"size-greater-than",
+			Assertion{
+				From:     FromTypeResponseSize,
+				Operator: AssertionOperatorGreaterThan,
+				Value:    "3",
+			},
+			&http.Response{
+				Body: newReaderCloser(`12345`),
+			},
+			false,
+		},
+		{
+			"size-less-than",
+			Assertion{
+				From:     FromTypeResponseSize,
+				Operator: AssertionOperatorLessThan,
+				Value:    "3",
+			},
+			&http.Response{
+				Body: newReaderCloser(`12345`),
+			},
+			true,
+		},
+		{
+			"nil-response",
+			Assertion{
+				From:     FromTypeBodyRaw,
+				Operator: AssertionOperatorExists,
+			},
+			nil,
+			true,
+		},
+	}
+
+	for _, testdata := range tests {
+		err := testdata.Assertion.Evaluate(testdata.Resp)
+		if err == nil && testdata.ExpectErr {
+			t.Errorf("[%s] expected error but got none", testdata.TestName)
+			continue
+		}
+		if err != nil && !testdata.ExpectErr {
+			t.Errorf("[%s] got unexpected err: %s", testdata.TestName, err)
+			continue
+		}
+		var assertionErr *AssertionError
+		if err != nil && !errors.As(err, &assertionErr) {
+			t.Errorf("[%s] expected an AssertionError, got: %T", testdata.TestName, err)
+		}
+	}
+}
+
+func TestHttpRequest_handleResponseAssertions(t *testing.T) {
+	r := &HttpRequest{
+		ExpectedResponseCodes: []int{200},
+		Assertions: []Assertion{
+			{
+				Name:     "status is ok",
+				From:     FromTypeBodyJson,
+				JsonPath: "/status",
+				Value:    "ok",
+			},
+		},
+	}
+
+	err := r.handleResponse(context.Background(), &RequestResult{Response: &http.Response{
+		StatusCode: 200,
+		Body:       newReaderCloser(`{"status": "error"}`),
+	}})
+	var assertionErr *AssertionError
+	if !errors.As(err, &assertionErr) {
+		t.Fatalf("expected an AssertionError, got: %v", err)
+	}
+	if assertionErr.Assertion != "status is ok" {
+		t.Errorf("unexpected assertion name: %s", assertionErr.Assertion)
+	}
+}
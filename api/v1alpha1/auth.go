@@ -0,0 +1,113 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/oregondesignservices/monitoring-controller/internal/auth"
+	corev1 "k8s.io/api/core/v1"
+	"net/http"
+	"net/url"
+)
+
+// The auth used by a request. A request's own auth overrides the monitor's.
+func (h *HttpMonitor) authFor(r *HttpRequest) *Auth {
+	if r.Auth != nil {
+		return r.Auth
+	}
+	return h.Spec.Auth
+}
+
+// All Secrets referenced by the auth
+func (a *Auth) referencedSecrets() []string {
+	if a == nil {
+		return nil
+	}
+	var names []string
+	if a.Basic != nil {
+		names = append(names, a.Basic.Username.Name, a.Basic.Password.Name)
+	}
+	if a.Bearer != nil {
+		names = append(names, a.Bearer.Token.Name)
+	}
+	if a.OAuth2 != nil {
+		names = append(names, a.OAuth2.ClientId.Name, a.OAuth2.ClientSecret.Name)
+	}
+	return names
+}
+
+func (f *resourceFetcher) secretKey(ctx context.Context, selector *corev1.SecretKeySelector) (value string, found bool, err error) {
+	value, _, found, err = f.value(ctx, &VariableSource{SecretKeyRef: selector})
+	return value, found, err
+}
+
+// Resolve the headers to add to the request. If a key of an optional secret is missing, no header is added.
+func (a *Auth) headers(ctx context.Context, fetcher *resourceFetcher, httpClient *http.Client, tokens *auth.TokenCache) (http.Header, error) {
+	if a == nil {
+		return nil, nil
+	}
+	header := make(http.Header)
+
+	switch {
+	case a.Basic != nil:
+		username, found, err := fetcher.secretKey(ctx, &a.Basic.Username)
+		if err != nil || !found {
+			return nil, err
+		}
+		password, found, err := fetcher.secretKey(ctx, &a.Basic.Password)
+		if err != nil || !found {
+			return nil, err
+		}
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+	case a.Bearer != nil:
+		token, found, err := fetcher.secretKey(ctx, &a.Bearer.Token)
+		if err != nil || !found {
+			return nil, err
+		}
+		header.Set("Authorization", "Bearer "+token)
+	case a.OAuth2 != nil:
+		clientId, found, err := fetcher.secretKey(ctx, &a.OAuth2.ClientId)
+		if err != nil || !found {
+			return nil, err
+		}
+		clientSecret, found, err := fetcher.secretKey(ctx, &a.OAuth2.ClientSecret)
+		if err != nil || !found {
+			return nil, err
+		}
+		params := make(url.Values)
+		for key, value := range a.OAuth2.EndpointParams {
+			params.Set(key, value)
+		}
+		token, err := tokens.ClientCredentialsToken(ctx, httpClient, a.OAuth2.TokenUrl, clientId, clientSecret, a.OAuth2.Scopes, params)
+		if err != nil {
+			return nil, errors.New("failed to get oauth2 token: " + err.Error())
+		}
+		header.Set("Authorization", token.Type()+" "+token.AccessToken)
+	}
+
+	return header, nil
+}
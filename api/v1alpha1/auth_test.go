@@ -0,0 +1,240 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"context"
+	"github.com/oregondesignservices/monitoring-controller/internal/auth"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"net/http"
+	"net/http/httptest"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+	"time"
+)
+
+func secretKey(name, key string) corev1.SecretKeySelector {
+	return corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: name},
+		Key:                  key,
+	}
+}
+
+func optionalSecretKey(name, key string) corev1.SecretKeySelector {
+	selector := secretKey(name, key)
+	optional := true
+	selector.Optional = &optional
+	return selector
+}
+
+func TestAuth_headers(t *testing.T) {
+	tokenRequests := 0
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token": "oauth-token", "token_type": "Bearer", "expires_in": 3600}`))
+	}))
+	defer tokenServer.Close()
+
+	reader := fake.NewFakeClientWithScheme(scheme.Scheme,
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "creds"},
+			Data: map[string][]byte{
+				"username":      []byte("user"),
+				"password":      []byte("pass"),
+				"token":         []byte("static-token"),
+				"client_id":     []byte("id"),
+				"client_secret": []byte("secret"),
+			},
+		},
+	)
+
+	tests := []struct {
+		TestName      string
+		Auth          *Auth
+		ExpectErr     bool
+		ExpectedValue string
+	}{
+		{
+			"basic",
+			&Auth{Basic: &BasicAuth{
+				Username: secretKey("creds", "username"),
+				Password: secretKey("creds", "password"),
+			}},
+			false,
+			"Basic dXNlcjpwYXNz",
+		},
+		{
+			"bearer",
+			&Auth{Bearer: &BearerAuth{
+				Token: secretKey("creds", "token"),
+			}},
+			false,
+			"Bearer static-token",
+		},
+		{
+			"oauth2",
+			&Auth{OAuth2: &OAuth2ClientCredentials{
+				TokenUrl:     tokenServer.URL,
+				ClientId:     secretKey("creds", "client_id"),
+				ClientSecret: secretKey("creds", "client_secret"),
+			}},
+			false,
+			"Bearer oauth-token",
+		},
+		{
+			"oauth2-cached",
+			&Auth{OAuth2: &OAuth2ClientCredentials{
+				TokenUrl:     tokenServer.URL,
+				ClientId:     secretKey("creds", "client_id"),
+				ClientSecret: secretKey("creds", "client_secret"),
+			}},
+			false,
+			"Bearer oauth-token",
+		},
+		{
+			"missing-secret-key",
+			&Auth{Bearer: &BearerAuth{
+				Token: secretKey("creds", "not-real"),
+			}},
+			true,
+			"",
+		},
+		{
+			"optional-missing-bearer",
+			&Auth{Bearer: &BearerAuth{
+				Token: optionalSecretKey("creds", "not-real"),
+			}},
+			false,
+			"",
+		},
+		{
+			"optional-missing-basic-password",
+			&Auth{Basic: &BasicAuth{
+				Username: secretKey("creds", "username"),
+				Password: optionalSecretKey("creds", "not-real"),
+			}},
+			false,
+			"",
+		},
+		{
+			"optional-missing-oauth2-secret",
+			&Auth{OAuth2: &OAuth2ClientCredentials{
+				TokenUrl:     tokenServer.URL,
+				ClientId:     secretKey("creds", "client_id"),
+				ClientSecret: optionalSecretKey("missing", "client_secret"),
+			}},
+			false,
+			"",
+		},
+		{
+			"no-auth",
+			&Auth{},
+			false,
+			"",
+		},
+	}
+
+	tokens := auth.NewTokenCache()
+	for _, testdata := range tests {
+		fetcher := newResourceFetcher(reader, "ns")
+		header, err := testdata.Auth.headers(context.Background(), fetcher, tokenServer.Client(), tokens)
+		if err == nil && testdata.ExpectErr {
+			t.Errorf("[%s] expected error but got none", testdata.TestName)
+			continue
+		}
+		if err != nil && !testdata.ExpectErr {
+			t.Errorf("[%s] got unexpected err: %s", testdata.TestName, err)
+			continue
+		}
+		if header.Get("Authorization") != testdata.ExpectedValue {
+			t.Errorf("[%s] unexpected header. Got: %s, expected: %s", testdata.TestName, header.Get("Authorization"), testdata.ExpectedValue)
+		}
+	}
+
+	if tokenRequests != 1 {
+		t.Errorf("expected the oauth2 token to be cached, got %d token requests", tokenRequests)
+	}
+}
+
+func TestAuth_headersCancelled(t *testing.T) {
+	release := make(chan struct{})
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer tokenServer.Close()
+	defer close(release)
+
+	reader := fake.NewFakeClientWithScheme(scheme.Scheme,
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "creds"},
+			Data: map[string][]byte{
+				"client_id":     []byte("hanging-id"),
+				"client_secret": []byte("secret"),
+			},
+		},
+	)
+	a := &Auth{OAuth2: &OAuth2ClientCredentials{
+		TokenUrl:     tokenServer.URL,
+		ClientId:     secretKey("creds", "client_id"),
+		ClientSecret: secretKey("creds", "client_secret"),
+	}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := a.headers(ctx, newResourceFetcher(reader, "ns"), tokenServer.Client(), auth.NewTokenCache())
+	if err == nil {
+		t.Errorf("expected error from a hanging token endpoint")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("token fetch was not cancelled, took %s", elapsed)
+	}
+}
+
+func TestHttpRequest_BuildRequestAuthHeaders(t *testing.T) {
+	r := &HttpRequest{
+		Url:         "http://test.com",
+		AuthHeaders: http.Header{"Authorization": []string{"Bearer abc"}},
+	}
+	req, err := r.BuildRequest(context.Background())
+	if err != nil {
+		t.Fatalf("got err while building request: %s", err)
+	}
+	if req.Header.Get("Authorization") != "Bearer abc" {
+		t.Errorf("auth header not injected: %v", req.Header)
+	}
+
+	r.Headers = http.Header{"Authorization": []string{"Basic explicit"}}
+	req, err = r.BuildRequest(context.Background())
+	if err != nil {
+		t.Fatalf("got err while building request: %s", err)
+	}
+	if req.Header.Get("Authorization") != "Basic explicit" {
+		t.Errorf("explicit header was overridden: %v", req.Header)
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"github.com/google/uuid"
+	"github.com/oregondesignservices/monitoring-controller/internal/conf"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"strconv"
+	"time"
+)
+
+// State shared by the built-in variables of a single run
+type runInfo struct {
+	monitor  *HttpMonitor
+	sequence int64
+	start    time.Time
+	username string
+}
+
+func newRunInfo(monitor *HttpMonitor, sequence int64, start time.Time) *runInfo {
+	return &runInfo{
+		monitor:  monitor,
+		sequence: sequence,
+		start:    start,
+		username: "monitor-" + rand.String(10),
+	}
+}
+
+// A variable available to every request without being defined by the monitor
+// +kubebuilder:object:generate=false
+type BuiltinVariable struct {
+	Name        string
+	Description string
+	value       func(run *runInfo) string
+}
+
+// All built-in variables. Each is computed once at the start of a run, so every request sees the same value.
+var BuiltinVariables = []BuiltinVariable{
+	{
+		Name:        "random-8",
+		Description: "8 random alphanumeric characters",
+		value:       func(run *runInfo) string { return rand.String(8) },
+	},
+	{
+		Name:        "random-16",
+		Description: "16 random alphanumeric characters",
+		value:       func(run *runInfo) string { return rand.String(16) },
+	},
+	{
+		Name:        "uuid",
+		Description: "a random UUID (version 4)",
+		value:       func(run *runInfo) string { return uuid.New().String() },
+	},
+	{
+		Name:        "run-timestamp",
+		Description: "when the run started, RFC 3339 in UTC, ex: 2020-06-01T15:04:05Z",
+		value:       func(run *runInfo) string { return run.start.UTC().Format(time.RFC3339) },
+	},
+	{
+		Name:        "run-timestamp-unix",
+		Description: "when the run started, in seconds since the unix epoch",
+		value:       func(run *runInfo) string { return strconv.FormatInt(run.start.Unix(), 10) },
+	},
+	{
+		Name:        "run-timestamp-unix-millis",
+		Description: "when the run started, in milliseconds since the unix epoch",
+		value: func(run *runInfo) string {
+			return strconv.FormatInt(run.start.UnixNano()/int64(time.Millisecond), 10)
+		},
+	},
+	{
+		Name:        "run-date",
+		Description: "the day the run started in UTC, ex: 2020-06-01",
+		value:       func(run *runInfo) string { return run.start.UTC().Format("2006-01-02") },
+	},
+	{
+		Name:        "run-sequence",
+		Description: "the number of this run, starting at 1. Resets when the monitor is changed or the controller restarts",
+		value:       func(run *runInfo) string { return strconv.FormatInt(run.sequence, 10) },
+	},
+	{
+		Name:        "monitor-name",
+		Description: "the name of the HttpMonitor",
+		value:       func(run *runInfo) string { return run.monitor.Name },
+	},
+	{
+		Name:        "monitor-namespace",
+		Description: "the namespace of the HttpMonitor",
+		value:       func(run *runInfo) string { return run.monitor.Namespace },
+	},
+	{
+		Name:        "pod-name",
+		Description: "the name of the controller pod running the monitor",
+		value:       func(run *runInfo) string { return conf.GlobalConfig.PodName },
+	},
+	{
+		Name:        "random-username",
+		Description: "a random username for signup flows, ex: monitor-x8k2mz9q4w",
+		value:       func(run *runInfo) string { return run.username },
+	},
+	{
+		Name:        "random-email",
+		Description: "an email address for `random-username` at the domain set by --random-email-domain",
+		value:       func(run *runInfo) string { return run.username + "@" + conf.GlobalConfig.RandomEmailDomain },
+	},
+}
+
+func isBuiltinVariable(name string) bool {
+	for _, builtin := range BuiltinVariables {
+		if builtin.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// The values of all built-in variables for a run
+func builtinVariables(run *runInfo) VariableList {
+	variables := make(VariableList, len(BuiltinVariables))
+	for i, builtin := range BuiltinVariables {
+		variables[i] = &Variable{
+			Name:  builtin.Name,
+			From:  FromTypeProvided,
+			Value: builtin.value(run),
+		}
+	}
+	return variables
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"github.com/oregondesignservices/monitoring-controller/internal/httpclient"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuiltinVariables(t *testing.T) {
+	monitor := &HttpMonitor{}
+	monitor.Name = "monitor"
+	monitor.Namespace = "ns"
+	start := time.Date(2020, 6, 1, 15, 4, 5, 0, time.UTC)
+
+	values := builtinVariables(newRunInfo(monitor, 42, start)).values()
+
+	if len(values) != len(BuiltinVariables) {
+		t.Errorf("built-in variable names are not unique")
+	}
+
+	expected := map[string]string{
+		"run-timestamp":             "2020-06-01T15:04:05Z",
+		"run-timestamp-unix":        "1591023845",
+		"run-timestamp-unix-millis": "1591023845000",
+		"run-date":                  "2020-06-01",
+		"run-sequence":              "42",
+		"monitor-name":              "monitor",
+		"monitor-namespace":         "ns",
+	}
+	for name, value := range expected {
+		if values[name] != value {
+			t.Errorf("unexpected value for %s. Got: %s, expected: %s", name, values[name], value)
+		}
+	}
+
+	patterns := map[string]string{
+		"random-8":        "^[a-z0-9]{8}$",
+		"random-16":       "^[a-z0-9]{16}$",
+		"uuid":            "^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$",
+		"random-username": "^monitor-[a-z0-9]{10}$",
+	}
+	for name, pattern := range patterns {
+		if !regexp.MustCompile(pattern).MatchString(values[name]) {
+			t.Errorf("value for %s does not match %s: %s", name, pattern, values[name])
+		}
+	}
+
+	if !strings.HasPrefix(values["random-email"], values["random-username"]+"@") {
+		t.Errorf("random-email should use random-username. Got: %s and %s", values["random-email"], values["random-username"])
+	}
+
+	other := builtinVariables(newRunInfo(monitor, 43, start)).values()
+	if other["uuid"] == values["uuid"] || other["random-username"] == values["random-username"] {
+		t.Errorf("random values should change between runs")
+	}
+}
+
+// Monitors that already used a name that is now a built-in keep their own value
+func TestHttpMonitor_ExecuteUserVariablesShadowBuiltins(t *testing.T) {
+	httpclient.Initialize(time.Second)
+
+	var visited []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		visited = append(visited, r.URL.Path)
+		_, _ = w.Write([]byte(`{"run-date": "from-response"}`))
+	}))
+	defer server.Close()
+
+	monitor := &HttpMonitor{Spec: HttpMonitorSpec{
+		Environment: map[string]string{"uuid": "fixed"},
+		Requests: []HttpRequest{
+			{
+				Name:                  "first",
+				Method:                "GET",
+				Url:                   server.URL + "/{uuid}/{run-sequence}",
+				ExpectedResponseCodes: []int{200},
+				VariablesFromResponse: VariableList{
+					&Variable{Name: "run-date", From: FromTypeBodyJson, JsonPath: "/run-date"},
+				},
+			},
+			{Name: "second", Method: "GET", Url: server.URL + "/{run-date}", ExpectedResponseCodes: []int{200}},
+		},
+	}}
+
+	monitor.Execute(context.Background(), nil, &RunnerState{})
+	expected := []string{"/fixed/1", "/from-response"}
+	if fmt.Sprint(visited) != fmt.Sprint(expected) {
+		t.Errorf("unexpected requests. Got: %v, expected: %v", visited, expected)
+	}
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"context"
+	"github.com/oregondesignservices/monitoring-controller/internal/httpclient"
+	"net/http"
+)
+
+func (c *ClientConfig) apply(config *httpclient.Config) {
+	config.ProxyUrl = c.ProxyUrl
+	config.DisableRedirects = c.FollowRedirects != nil && !*c.FollowRedirects
+	config.MaxRedirects = c.MaxRedirects
+	config.DisableKeepAlives = c.DisableKeepAlives
+	config.Protocol = c.Protocol
+	config.UserAgent = c.UserAgent
+}
+
+// The client used for all requests of the monitor. Monitors without TLS or client settings share the global client.
+// Other clients are cached, and ref keeps the client used by the runner.
+func (h *HttpMonitor) httpClient(ctx context.Context, fetcher *resourceFetcher, ref *httpclient.Ref) (*http.Client, error) {
+	if h.Spec.TLS == nil && h.Spec.Client == nil {
+		httpclient.Release(ref)
+		return httpclient.GetClient(), nil
+	}
+
+	config := httpclient.Config{}
+	if h.Spec.TLS != nil {
+		var err error
+		config, err = h.Spec.TLS.clientConfig(ctx, fetcher)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if h.Spec.Client != nil {
+		h.Spec.Client.apply(&config)
+	}
+	return httpclient.GetClientFor(ref, config)
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"context"
+	"github.com/oregondesignservices/monitoring-controller/internal/httpclient"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHttpMonitor_httpClientSettings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/final", http.StatusFound)
+			return
+		}
+		if r.URL.Path == "/loop" {
+			http.Redirect(w, r, "/loop", http.StatusFound)
+			return
+		}
+		w.Header().Set("X-User-Agent", r.UserAgent())
+		w.Header().Set("X-Proto", r.Proto)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	noFollow := false
+
+	tests := []struct {
+		TestName          string
+		Client            *ClientConfig
+		Path              string
+		ExpectErr         bool
+		ExpectedStatus    int
+		ExpectedUserAgent string
+	}{
+		{
+			"follow-redirects-by-default",
+			&ClientConfig{},
+			"/redirect",
+			false,
+			http.StatusOK,
+			"Go-http-client/1.1",
+		},
+		{
+			"do-not-follow-redirects",
+			&ClientConfig{FollowRedirects: &noFollow},
+			"/redirect",
+			false,
+			http.StatusFound,
+			"",
+		},
+		{
+			"max-redirects",
+			&ClientConfig{MaxRedirects: 3},
+			"/loop",
+			true,
+			0,
+			"",
+		},
+		{
+			"user-agent",
+			&ClientConfig{UserAgent: "monitoring-controller/test"},
+			"/",
+			false,
+			http.StatusOK,
+			"monitoring-controller/test",
+		},
+		{
+			"http1-without-keep-alives",
+			&ClientConfig{Protocol: "http1", DisableKeepAlives: true},
+			"/",
+			false,
+			http.StatusOK,
+			"Go-http-client/1.1",
+		},
+		{
+			// The test server only speaks HTTP/1.1
+			"http2-required",
+			&ClientConfig{Protocol: "http2"},
+			"/",
+			true,
+			0,
+			"",
+		},
+	}
+
+	for _, testdata := range tests {
+		monitor := &HttpMonitor{
+			Spec: HttpMonitorSpec{Client: testdata.Client},
+		}
+		client, err := monitor.httpClient(context.Background(), newResourceFetcher(nil, ""), &httpclient.Ref{})
+		if err != nil {
+			t.Errorf("[%s] failed to build client: %s", testdata.TestName, err)
+			continue
+		}
+		resp, err := client.Get(server.URL + testdata.Path)
+		if err == nil && testdata.ExpectErr {
+			t.Errorf("[%s] expected error but got none", testdata.TestName)
+			continue
+		}
+		if err != nil && !testdata.ExpectErr {
+			t.Errorf("[%s] got unexpected err: %s", testdata.TestName, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if resp.StatusCode != testdata.ExpectedStatus {
+			t.Errorf("[%s] unexpected status. Got: %d, expected: %d", testdata.TestName, resp.StatusCode, testdata.ExpectedStatus)
+		}
+		if userAgent := resp.Header.Get("X-User-Agent"); userAgent != testdata.ExpectedUserAgent {
+			t.Errorf("[%s] unexpected user agent. Got: %s, expected: %s", testdata.TestName, userAgent, testdata.ExpectedUserAgent)
+		}
+	}
+}
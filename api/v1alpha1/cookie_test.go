@@ -0,0 +1,116 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"context"
+	"github.com/oregondesignservices/monitoring-controller/internal/httpclient"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHttpMonitor_ExecuteCookieJar(t *testing.T) {
+	httpclient.Initialize(time.Second)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc123", Path: "/"})
+			w.WriteHeader(http.StatusOK)
+		case "/profile":
+			if _, err := r.Cookie("session"); err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	login := HttpRequest{
+		Name:                  "login",
+		Method:                "GET",
+		Url:                   server.URL + "/login",
+		ExpectedResponseCodes: []int{200},
+	}
+	profile := HttpRequest{
+		Name:                  "profile",
+		Method:                "GET",
+		Url:                   server.URL + "/profile",
+		ExpectedResponseCodes: []int{200},
+		VariablesFromResponse: VariableList{
+			&Variable{Name: "session", From: FromTypeCookie, JsonPath: "/session"},
+		},
+	}
+
+	tests := []struct {
+		TestName         string
+		CookieJar        *CookieJarConfig
+		Requests         []HttpRequest
+		ExpectedFailures []bool
+	}{
+		{
+			"disabled",
+			nil,
+			[]HttpRequest{login, profile},
+			[]bool{true, true},
+		},
+		{
+			// The second run starts with an empty jar
+			"per-run",
+			&CookieJarConfig{},
+			[]HttpRequest{login, profile},
+			[]bool{false, true},
+		},
+		{
+			"persist-across-runs",
+			&CookieJarConfig{PersistAcrossRuns: true},
+			[]HttpRequest{login, profile},
+			[]bool{false, false},
+		},
+	}
+
+	for _, testdata := range tests {
+		monitor := &HttpMonitor{Spec: HttpMonitorSpec{
+			CookieJar: testdata.CookieJar,
+			Requests:  testdata.Requests,
+		}}
+		state := &RunnerState{}
+
+		for run, expectFailure := range testdata.ExpectedFailures {
+			result := monitor.Execute(context.Background(), nil, state)
+			if result.Failed() != expectFailure {
+				t.Errorf("[%s] run %d: unexpected failure. Got: %t, expected: %t", testdata.TestName, run+1, result.Failed(), expectFailure)
+			}
+			// Only the first run logs in
+			monitor.Spec.Requests = []HttpRequest{profile}
+		}
+		if state.Sequence != int64(len(testdata.ExpectedFailures)) {
+			t.Errorf("[%s] unexpected sequence: %d", testdata.TestName, state.Sequence)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"errors"
+	"fmt"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A `{name}` placeholder. JSON objects like `{"id": 1}` do not match.
+var placeholderRegex = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_.\-]*)\}`)
+
+// The names of all `{name}` placeholders in a text
+func placeholders(text string) []string {
+	var names []string
+	for _, match := range placeholderRegex.FindAllStringSubmatch(text, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// Calls fn with the url, body, headers and query params, which support substitution
+func (r *HttpRequest) eachTemplateField(path *field.Path, fn func(text string, path *field.Path)) {
+	fn(r.Url, path.Child("url"))
+	fn(r.Body, path.Child("body"))
+	for key, values := range r.Headers {
+		for _, value := range values {
+			fn(value, path.Child("headers").Key(key))
+		}
+	}
+	for key, values := range r.QueryParams {
+		fn(key, path.Child("query_params").Key(key))
+		for _, value := range values {
+			fn(value, path.Child("query_params").Key(key))
+		}
+	}
+}
+
+// Whether a placeholder is set by the request's own for_each
+func (r *HttpRequest) isLoopVariable(name string) bool {
+	if r.ForEach == nil {
+		return false
+	}
+	as := r.ForEach.as()
+	return name == as || name == as+"_index" || strings.HasPrefix(name, as+".")
+}
+
+// Sorted names of the placeholders that no variable defines
+func (r *HttpRequest) unresolvedVariables(variables VariableList) []string {
+	defined := make(map[string]bool, len(variables))
+	for _, variable := range variables {
+		defined[variable.Name] = true
+	}
+
+	var unresolved []string
+	r.eachTemplateField(field.NewPath("request"), func(text string, _ *field.Path) {
+		for _, name := range r.undefinedPlaceholders(text, defined) {
+			defined[name] = true // only report each name once
+			unresolved = append(unresolved, name)
+		}
+	})
+	sort.Strings(unresolved)
+	return unresolved
+}
+
+// Names of the placeholders in a text that are not in `defined`, or set by the request's own for_each
+func (r *HttpRequest) undefinedPlaceholders(text string, defined map[string]bool) []string {
+	seen := make(map[string]bool)
+	var undefined []string
+	for _, name := range placeholders(text) {
+		if defined[name] || seen[name] || r.isLoopVariable(name) {
+			continue
+		}
+		seen[name] = true
+		undefined = append(undefined, name)
+	}
+	return undefined
+}
+
+// Cleanup requests are skipped by default, since they usually need what a failed request did not create,
+// ex: `DELETE /user/{userid}` after creating the user failed.
+func (h *HttpMonitor) onUnresolvedFor(r *HttpRequest, cleanup bool) UnresolvedPolicy {
+	if r.OnUnresolved != "" {
+		return r.OnUnresolved
+	}
+	if h.Spec.OnUnresolved != "" {
+		return h.Spec.OnUnresolved
+	}
+	if cleanup {
+		return UnresolvedPolicySkip
+	}
+	if h.isStrict() {
+		return UnresolvedPolicyError
+	}
+	return UnresolvedPolicySend
+}
+
+// Why a request cannot run yet, or "" if everything it depends on is available
+func (e *execution) missingDependency(r *HttpRequest) string {
+	if r.DependsOn == nil {
+		return ""
+	}
+	for _, name := range r.DependsOn.Variables {
+		if !e.defined(name) {
+			return fmt.Sprintf("variable %s is not defined", name)
+		}
+	}
+	for _, name := range r.DependsOn.Requests {
+		if !e.succeeded(name) {
+			return fmt.Sprintf("request %s did not succeed", name)
+		}
+	}
+	return ""
+}
+
+// Check the dependencies and placeholders of a request before sending it.
+// Returns a reason to skip the request, or an error if it should fail without being sent.
+func (e *execution) checkDependencies(r *HttpRequest) (string, error) {
+	if reason := e.missingDependency(r); reason != "" {
+		return reason, nil
+	}
+
+	unresolved := r.unresolvedVariables(e.available())
+	if len(unresolved) == 0 {
+		return "", nil
+	}
+	message := "unresolved variables: " + strings.Join(unresolved, ", ")
+	switch e.monitor.onUnresolvedFor(r, e.cleanup) {
+	case UnresolvedPolicySkip:
+		return message, nil
+	case UnresolvedPolicyError:
+		return "", errors.New(message)
+	}
+	return "", nil
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"github.com/oregondesignservices/monitoring-controller/internal/httpclient"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHttpRequest_unresolvedVariables(t *testing.T) {
+	variables := VariableList{
+		&Variable{Name: "host", Value: "test.com"},
+		&Variable{Name: "empty", Value: ""},
+	}
+
+	tests := []struct {
+		TestName string
+		Request  HttpRequest
+		Expected []string
+	}{
+		{"resolved", HttpRequest{Url: "http://{host}/{empty}"}, nil},
+		{"url", HttpRequest{Url: "http://{host}/user/{userid}"}, []string{"userid"}},
+		{"json-body", HttpRequest{Body: `{"id": "{userid}", "nested": {"a": 1}}`}, []string{"userid"}},
+		{"headers-and-query", HttpRequest{
+			Headers:     http.Header{"Authorization": []string{"Bearer {token}"}},
+			QueryParams: map[string][]string{"{key}": {"{value}", "{token}"}},
+		}, []string{"key", "token", "value"}},
+		{"for-each", HttpRequest{
+			Url:     "http://{host}/{item}/{item.id}/{item_index}/{other.id}",
+			ForEach: &ForEach{Variable: "items"},
+		}, []string{"other.id"}},
+		{"go-template", HttpRequest{Url: "http://{host}/{{ .userid }}"}, nil},
+	}
+
+	for _, testdata := range tests {
+		unresolved := testdata.Request.unresolvedVariables(variables)
+		if fmt.Sprint(unresolved) != fmt.Sprint(testdata.Expected) {
+			t.Errorf("[%s] Got: %v, expected: %v", testdata.TestName, unresolved, testdata.Expected)
+		}
+	}
+}
+
+func TestExecution_checkDependencies(t *testing.T) {
+	tests := []struct {
+		TestName        string
+		Request         HttpRequest
+		MonitorPolicy   UnresolvedPolicy
+		ExpectedSkipped bool
+		ExpectErr       bool
+	}{
+		{"no-dependencies", HttpRequest{Url: "/user/{userid}"}, "", false, false},
+		{"variable-defined", HttpRequest{DependsOn: &DependsOn{Variables: []string{"token"}}}, "", false, false},
+		{"variable-empty", HttpRequest{DependsOn: &DependsOn{Variables: []string{"empty"}}}, "", true, false},
+		{"request-succeeded", HttpRequest{DependsOn: &DependsOn{Requests: []string{"create user"}}}, "", false, false},
+		{"request-failed", HttpRequest{DependsOn: &DependsOn{Requests: []string{"get user"}}}, "", true, false},
+		{"request-not-run", HttpRequest{DependsOn: &DependsOn{Requests: []string{"delete user"}}}, "", true, false},
+		{"unresolved-skip", HttpRequest{Url: "/user/{userid}"}, UnresolvedPolicySkip, true, false},
+		{"unresolved-error", HttpRequest{Url: "/user/{userid}"}, UnresolvedPolicyError, false, true},
+		{"unresolved-request-overrides", HttpRequest{Url: "/user/{userid}", OnUnresolved: UnresolvedPolicySend}, UnresolvedPolicyError, false, false},
+		{"unresolved-strict", HttpRequest{Url: "/user/{userid}"}, "", false, true},
+	}
+
+	for _, testdata := range tests {
+		strict := testdata.TestName == "unresolved-strict"
+		e := &execution{
+			monitor: &HttpMonitor{Spec: HttpMonitorSpec{OnUnresolved: testdata.MonitorPolicy, Strict: &strict}},
+			variables: VariableList{
+				&Variable{Name: "token", Value: "abc"},
+				&Variable{Name: "empty", Value: ""},
+			},
+			result: &ExecutionResult{
+				Requests: []RequestStatus{
+					{Name: "get user", StatusCode: 404, Error: "not an expected error code"},
+					{Name: "create user", StatusCode: 201},
+				},
+			},
+		}
+
+		reason, err := e.checkDependencies(&testdata.Request)
+		if err == nil && testdata.ExpectErr {
+			t.Errorf("[%s] expected error but got none", testdata.TestName)
+		}
+		if err != nil && !testdata.ExpectErr {
+			t.Errorf("[%s] got unexpected err: %s", testdata.TestName, err)
+		}
+		if (reason != "") != testdata.ExpectedSkipped {
+			t.Errorf("[%s] unexpected skip reason: '%s'", testdata.TestName, reason)
+		}
+	}
+}
+
+func TestHttpMonitor_ExecuteCleanupDependsOn(t *testing.T) {
+	httpclient.Initialize(time.Second)
+
+	var visited []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		visited = append(visited, r.Method+" "+r.URL.Path)
+		if r.Method == "POST" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	monitor := &HttpMonitor{Spec: HttpMonitorSpec{
+		Requests: []HttpRequest{
+			{
+				Name:                  "create user",
+				Method:                "POST",
+				Url:                   server.URL + "/user",
+				ExpectedResponseCodes: []int{201},
+				VariablesFromResponse: VariableList{
+					&Variable{Name: "userid", From: FromTypeBodyJson, JsonPath: "/id"},
+				},
+			},
+		},
+		Cleanup: []HttpRequest{
+			{
+				Name:                  "delete user",
+				Method:                "DELETE",
+				Url:                   server.URL + "/user/{userid}",
+				ExpectedResponseCodes: []int{200},
+				DependsOn:             &DependsOn{Requests: []string{"create user"}},
+			},
+			{
+				Name:                  "delete user sessions",
+				Method:                "DELETE",
+				Url:                   server.URL + "/user/{userid}/sessions",
+				ExpectedResponseCodes: []int{200},
+				OnUnresolved:          UnresolvedPolicySkip,
+			},
+			{
+				Name:                  "logout",
+				Method:                "GET",
+				Url:                   server.URL + "/logout",
+				ExpectedResponseCodes: []int{200},
+			},
+		},
+	}}
+
+	result := monitor.Execute(context.Background(), nil, &RunnerState{})
+	expected := []string{"POST /user", "GET /logout"}
+	if fmt.Sprint(visited) != fmt.Sprint(expected) {
+		t.Errorf("unexpected requests. Got: %v, expected: %v", visited, expected)
+	}
+	if len(result.Cleanup) != 3 {
+		t.Fatalf("expected a status for every cleanup request, got: %d", len(result.Cleanup))
+	}
+	for _, status := range result.Cleanup[:2] {
+		if status.Skipped == "" || status.Error != "" {
+			t.Errorf("expected %s to be skipped, got: %+v", status.Name, status)
+		}
+	}
+}
+
+// Without depends_on, a cleanup request for a user that was never created is skipped instead of sent
+func TestHttpMonitor_ExecuteCleanupUnresolved(t *testing.T) {
+	httpclient.Initialize(time.Second)
+
+	var visited []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		visited = append(visited, r.Method+" "+r.URL.EscapedPath())
+		if r.Method == "POST" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		TestName        string
+		OnUnresolved    UnresolvedPolicy
+		ExpectedVisited []string
+		ExpectedSkipped bool
+	}{
+		{"skipped-by-default", "", []string{"POST /user"}, true},
+		{"monitor-policy-applies", UnresolvedPolicySend, []string{"POST /user", "DELETE /user/%7Buserid%7D"}, false},
+	}
+
+	for _, testdata := range tests {
+		visited = nil
+		monitor := &HttpMonitor{Spec: HttpMonitorSpec{
+			OnUnresolved: testdata.OnUnresolved,
+			Requests: []HttpRequest{
+				{
+					Name:                  "create user",
+					Method:                "POST",
+					Url:                   server.URL + "/user",
+					ExpectedResponseCodes: []int{201},
+					VariablesFromResponse: VariableList{
+						&Variable{Name: "userid", From: FromTypeBodyJson, JsonPath: "/id"},
+					},
+				},
+			},
+			Cleanup: []HttpRequest{
+				{Name: "delete user", Method: "DELETE", Url: server.URL + "/user/{userid}", ExpectedResponseCodes: []int{200}},
+			},
+		}}
+
+		result := monitor.Execute(context.Background(), nil, &RunnerState{})
+		if fmt.Sprint(visited) != fmt.Sprint(testdata.ExpectedVisited) {
+			t.Errorf("[%s] unexpected requests. Got: %v, expected: %v", testdata.TestName, visited, testdata.ExpectedVisited)
+		}
+		if len(result.Cleanup) != 1 || (result.Cleanup[0].Skipped != "") != testdata.ExpectedSkipped {
+			t.Errorf("[%s] unexpected cleanup result: %+v", testdata.TestName, result.Cleanup)
+		}
+	}
+}
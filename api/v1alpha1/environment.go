@@ -0,0 +1,304 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/oregondesignservices/monitoring-controller/internal/conf"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"sync"
+)
+
+func isOptional(optional *bool) bool {
+	return optional != nil && *optional
+}
+
+// Names of all Secrets referenced by the spec
+func (h *HttpMonitor) ReferencedSecrets() []string {
+	names := make(map[string]bool)
+	for _, env := range h.Spec.EnvironmentRefs {
+		if env.ValueFrom.SecretKeyRef != nil {
+			names[env.ValueFrom.SecretKeyRef.Name] = true
+		}
+	}
+	for _, env := range h.Spec.EnvFrom {
+		if env.SecretRef != nil {
+			names[env.SecretRef.Name] = true
+		}
+	}
+	auths := []*Auth{h.Spec.Auth}
+	for i := range h.Spec.Requests {
+		auths = append(auths, h.Spec.Requests[i].Auth)
+	}
+	for i := range h.Spec.Cleanup {
+		auths = append(auths, h.Spec.Cleanup[i].Auth)
+	}
+	for _, a := range auths {
+		for _, name := range a.referencedSecrets() {
+			names[name] = true
+		}
+	}
+	if tlsConfig := h.Spec.TLS; tlsConfig != nil {
+		if tlsConfig.CA != nil && tlsConfig.CA.SecretKeyRef != nil {
+			names[tlsConfig.CA.SecretKeyRef.Name] = true
+		}
+		if tlsConfig.ClientCert != nil {
+			names[tlsConfig.ClientCert.Name] = true
+		}
+		if tlsConfig.ClientKey != nil {
+			names[tlsConfig.ClientKey.Name] = true
+		}
+	}
+	return sortedKeys(names)
+}
+
+// Names of all ConfigMaps referenced by the spec
+func (h *HttpMonitor) ReferencedConfigMaps() []string {
+	names := make(map[string]bool)
+	for _, env := range h.Spec.EnvironmentRefs {
+		if env.ValueFrom.ConfigMapKeyRef != nil {
+			names[env.ValueFrom.ConfigMapKeyRef.Name] = true
+		}
+	}
+	for _, env := range h.Spec.EnvFrom {
+		if env.ConfigMapRef != nil {
+			names[env.ConfigMapRef.Name] = true
+		}
+	}
+	if h.Spec.TLS != nil && h.Spec.TLS.CA != nil && h.Spec.TLS.CA.ConfigMapKeyRef != nil {
+		names[h.Spec.TLS.CA.ConfigMapKeyRef.Name] = true
+	}
+	return sortedKeys(names)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Fetches Secrets and ConfigMaps, caching them for the duration of a run
+type resourceFetcher struct {
+	reader    client.Reader
+	namespace string
+
+	// Requests in a parallel group share the fetcher
+	lock       sync.Mutex
+	secrets    map[string]*corev1.Secret
+	configMaps map[string]*corev1.ConfigMap
+}
+
+func newResourceFetcher(reader client.Reader, namespace string) *resourceFetcher {
+	return &resourceFetcher{
+		reader:     reader,
+		namespace:  namespace,
+		secrets:    make(map[string]*corev1.Secret),
+		configMaps: make(map[string]*corev1.ConfigMap),
+	}
+}
+
+var errNoReader = errors.New("cannot read secrets or config maps without a client")
+
+// Returns nil without an error if the secret does not exist
+func (f *resourceFetcher) secret(ctx context.Context, name string) (*corev1.Secret, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if secret, ok := f.secrets[name]; ok {
+		return secret, nil
+	}
+	if f.reader == nil {
+		return nil, errNoReader
+	}
+	secret := &corev1.Secret{}
+	err := f.reader.Get(ctx, types.NamespacedName{Namespace: f.namespace, Name: name}, secret)
+	if apierrors.IsNotFound(err) {
+		secret = nil
+	} else if err != nil {
+		return nil, err
+	}
+	f.secrets[name] = secret
+	return secret, nil
+}
+
+// Returns nil without an error if the config map does not exist
+func (f *resourceFetcher) configMap(ctx context.Context, name string) (*corev1.ConfigMap, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if configMap, ok := f.configMaps[name]; ok {
+		return configMap, nil
+	}
+	if f.reader == nil {
+		return nil, errNoReader
+	}
+	configMap := &corev1.ConfigMap{}
+	err := f.reader.Get(ctx, types.NamespacedName{Namespace: f.namespace, Name: name}, configMap)
+	if apierrors.IsNotFound(err) {
+		configMap = nil
+	} else if err != nil {
+		return nil, err
+	}
+	f.configMaps[name] = configMap
+	return configMap, nil
+}
+
+// Read a single value. `found` is false if the value is missing but optional.
+func (f *resourceFetcher) value(ctx context.Context, source *VariableSource) (value string, secret bool, found bool, err error) {
+	switch {
+	case source.SecretKeyRef != nil:
+		ref := source.SecretKeyRef
+		obj, err := f.secret(ctx, ref.Name)
+		if err != nil {
+			return "", true, false, err
+		}
+		if obj != nil {
+			if data, ok := obj.Data[ref.Key]; ok {
+				return string(data), true, true, nil
+			}
+		}
+		if isOptional(ref.Optional) {
+			return "", true, false, nil
+		}
+		return "", true, false, fmt.Errorf("secret key not found: %s/%s", ref.Name, ref.Key)
+	case source.ConfigMapKeyRef != nil:
+		ref := source.ConfigMapKeyRef
+		obj, err := f.configMap(ctx, ref.Name)
+		if err != nil {
+			return "", false, false, err
+		}
+		if obj != nil {
+			if data, ok := obj.Data[ref.Key]; ok {
+				return data, false, true, nil
+			}
+		}
+		if isOptional(ref.Optional) {
+			return "", false, false, nil
+		}
+		return "", false, false, fmt.Errorf("config map key not found: %s/%s", ref.Name, ref.Key)
+	}
+	return "", false, false, fmt.Errorf("value_from must set secret_key_ref or config_map_key_ref")
+}
+
+func (f *resourceFetcher) envFromVariables(ctx context.Context, source *EnvironmentFromSource) (VariableList, error) {
+	var variables VariableList
+	switch {
+	case source.SecretRef != nil:
+		obj, err := f.secret(ctx, source.SecretRef.Name)
+		if err != nil {
+			return nil, err
+		}
+		if obj == nil {
+			if isOptional(source.SecretRef.Optional) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("secret not found: %s", source.SecretRef.Name)
+		}
+		for key, value := range obj.Data {
+			variables = append(variables, &Variable{
+				Name:   source.Prefix + key,
+				From:   FromTypeProvided,
+				Value:  string(value),
+				Secret: true,
+			})
+		}
+	case source.ConfigMapRef != nil:
+		obj, err := f.configMap(ctx, source.ConfigMapRef.Name)
+		if err != nil {
+			return nil, err
+		}
+		if obj == nil {
+			if isOptional(source.ConfigMapRef.Optional) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("config map not found: %s", source.ConfigMapRef.Name)
+		}
+		for key, value := range obj.Data {
+			variables = append(variables, &Variable{
+				Name:  source.Prefix + key,
+				From:  FromTypeProvided,
+				Value: value,
+			})
+		}
+	default:
+		return nil, fmt.Errorf("env_from must set secret_ref or config_map_ref")
+	}
+	return variables, nil
+}
+
+func environmentVariables(environment map[string]string, secret bool) VariableList {
+	var variables VariableList
+	for key, val := range environment {
+		variables = append(variables, &Variable{
+			Name:   key,
+			From:   FromTypeProvided,
+			Value:  val,
+			Secret: secret,
+		})
+	}
+	return variables
+}
+
+// Variables from --set-secret-var, `env_from`, `environment` and `environment_refs`, in that order of precedence.
+// `environment` already includes any --set-var globals.
+func (h *HttpMonitor) resolveEnvironment(ctx context.Context, fetcher *resourceFetcher) (VariableList, error) {
+	variables := environmentVariables(conf.GlobalConfig.GlobalSecretRequestVars, true)
+
+	for i := range h.Spec.EnvFrom {
+		fromVariables, err := fetcher.envFromVariables(ctx, &h.Spec.EnvFrom[i])
+		if err != nil {
+			return nil, err
+		}
+		variables = variables.merge(fromVariables)
+	}
+
+	variables = variables.merge(environmentVariables(h.Spec.Environment, false))
+
+	for _, env := range h.Spec.EnvironmentRefs {
+		value, secret, found, err := fetcher.value(ctx, &env.ValueFrom)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+		variables = variables.merge(VariableList{
+			&Variable{
+				Name:   env.Name,
+				From:   FromTypeProvided,
+				Value:  value,
+				Secret: secret,
+			},
+		})
+	}
+	return variables, nil
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"context"
+	"errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func findVariable(v VariableList, name string) *Variable {
+	for _, variable := range v {
+		if variable.Name == name {
+			return variable
+		}
+	}
+	return nil
+}
+
+func TestHttpMonitor_resolveEnvironment(t *testing.T) {
+	reader := fake.NewFakeClientWithScheme(scheme.Scheme,
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "creds"},
+			Data: map[string][]byte{
+				"password": []byte("hunter2"),
+				"username": []byte("from-secret"),
+			},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "settings"},
+			Data: map[string]string{
+				"API_URL": "https://example.com",
+			},
+		},
+	)
+
+	optional := true
+	monitor := &HttpMonitor{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "monitor"},
+		Spec: HttpMonitorSpec{
+			Environment: map[string]string{
+				"CREDS_username": "from-environment",
+			},
+			EnvFrom: []EnvironmentFromSource{
+				{
+					Prefix:    "CREDS_",
+					SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "creds"}},
+				},
+				{
+					ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "settings"}},
+				},
+			},
+			EnvironmentRefs: []EnvironmentVariable{
+				{
+					Name: "PASSWORD",
+					ValueFrom: VariableSource{
+						SecretKeyRef: &corev1.SecretKeySelector{
+							LocalObjectReference: corev1.LocalObjectReference{Name: "creds"},
+							Key:                  "password",
+						},
+					},
+				},
+				{
+					Name: "OPTIONAL",
+					ValueFrom: VariableSource{
+						ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+							LocalObjectReference: corev1.LocalObjectReference{Name: "not-real"},
+							Key:                  "key",
+							Optional:             &optional,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	variables, err := monitor.resolveEnvironment(context.Background(), newResourceFetcher(reader, "ns"))
+	if err != nil {
+		t.Fatalf("got unexpected err: %s", err)
+	}
+
+	expected := map[string]struct {
+		Value  string
+		Secret bool
+	}{
+		"CREDS_password": {"hunter2", true},
+		"CREDS_username": {"from-environment", false},
+		"API_URL":        {"https://example.com", false},
+		"PASSWORD":       {"hunter2", true},
+	}
+	if len(variables) != len(expected) {
+		t.Errorf("unexpected number of variables: %d", len(variables))
+	}
+	for name, want := range expected {
+		variable := findVariable(variables, name)
+		if variable == nil {
+			t.Errorf("[%s] variable missing", name)
+			continue
+		}
+		if variable.Value != want.Value || variable.Secret != want.Secret {
+			t.Errorf("[%s] unexpected variable. Got: %s/%t, expected: %s/%t", name, variable.Value, variable.Secret, want.Value, want.Secret)
+		}
+	}
+
+	redactedErr := variables.redactError(errors.New(`Get "https://example.com/?p=hunter2": timeout`))
+	if redactedErr.Error() != `Get "https://example.com/?p=<redacted>": timeout` {
+		t.Errorf("secret not redacted: %s", redactedErr)
+	}
+
+	monitor.Spec.EnvironmentRefs[1].ValueFrom.ConfigMapKeyRef.Optional = nil
+	_, err = monitor.resolveEnvironment(context.Background(), newResourceFetcher(reader, "ns"))
+	if err == nil {
+		t.Errorf("expected an error for a missing required config map")
+	}
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/jmespath/go-jmespath"
+	"k8s.io/client-go/util/jsonpath"
+	"strconv"
+	"strings"
+)
+
+// Format an extracted value. Strings are used as is, everything else as JSON.
+func formatExpressionValue(value interface{}) (string, error) {
+	switch typed := value.(type) {
+	case string:
+		return typed, nil
+	case float64:
+		return strconv.FormatFloat(typed, 'f', -1, 64), nil
+	case json.Number:
+		return typed.String(), nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Integers beyond this lose precision as a float64
+const maxExactFloatInt = 1 << 53
+
+// Decode a JSON body for evaluating expressions. Numbers are float64, like with json.Unmarshal, except
+// for integers too large for a float64, like resource IDs, which are kept exact as json.Number.
+func decodeExpressionBody(jsonBody []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(jsonBody))
+	decoder.UseNumber()
+	var data interface{}
+	if err := decoder.Decode(&data); err != nil {
+		return nil, err
+	}
+	return normalizeNumbers(data), nil
+}
+
+func normalizeNumbers(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, elem := range typed {
+			typed[key] = normalizeNumbers(elem)
+		}
+	case []interface{}:
+		for i, elem := range typed {
+			typed[i] = normalizeNumbers(elem)
+		}
+	case json.Number:
+		if i, err := typed.Int64(); err == nil && (i > maxExactFloatInt || i < -maxExactFloatInt) {
+			return typed
+		}
+		if f, err := typed.Float64(); err == nil {
+			return f
+		}
+		return typed
+	}
+	return value
+}
+
+// Evaluate a kubernetes style JSONPath expression, ex: `{.items[?(@.name=="x")].id}`.
+// The braces are optional. Multiple results are separated by spaces.
+func evaluateJsonPath(expression string, jsonBody []byte) (string, error) {
+	if !strings.Contains(expression, "{") {
+		expression = "{" + expression + "}"
+	}
+	j := jsonpath.New("variable").AllowMissingKeys(true)
+	if err := j.Parse(expression); err != nil {
+		return "", err
+	}
+
+	data, err := decodeExpressionBody(jsonBody)
+	if err != nil {
+		return "", err
+	}
+
+	results, err := j.FindResults(data)
+	if err != nil {
+		return "", err
+	}
+	var values []string
+	for _, result := range results {
+		for _, r := range result {
+			value, err := formatExpressionValue(r.Interface())
+			if err != nil {
+				return "", err
+			}
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return "", notFound("no results for jsonpath: %s", expression)
+	}
+	return strings.Join(values, " "), nil
+}
+
+// Evaluate a JMESPath expression, ex: `items[?name=='x'] | [0].id` or `length(items)`
+func evaluateJmesPath(expression string, jsonBody []byte) (string, error) {
+	data, err := decodeExpressionBody(jsonBody)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := jmespath.Search(expression, data)
+	if err != nil {
+		return "", err
+	}
+	if result == nil {
+		return "", notFound("no results for jmespath: %s", expression)
+	}
+	return formatExpressionValue(result)
+}
+
+// Check that an expression is valid, without evaluating it
+func validateExpression(expressionType ExpressionType, expression string) error {
+	switch expressionType {
+	case ExpressionTypeJsonPath:
+		if !strings.Contains(expression, "{") {
+			expression = "{" + expression + "}"
+		}
+		return jsonpath.New("validate").Parse(expression)
+	case ExpressionTypeJmesPath:
+		_, err := jmespath.Compile(expression)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"testing"
+)
+
+func TestVariable_parseFromJsonBytesExpressions(t *testing.T) {
+	body := []byte(`{
+		"items": [
+			{"id": 1, "name": "a", "tags": ["x"]},
+			{"id": 2, "name": "b", "tags": ["y", "z"]},
+			{"id": 3, "name": "c", "owner": {"name": "bob"}}
+		],
+		"next": "https://example.com/page/2"
+	}`)
+
+	tests := []struct {
+		TestName       string
+		ExpressionType ExpressionType
+		Expression     string
+		ExpectErr      bool
+		ExpectedValue  string
+	}{
+		{
+			"slash-default",
+			"",
+			"/items/1/name",
+			false,
+			"b",
+		},
+		{
+			"jsonpath",
+			ExpressionTypeJsonPath,
+			".next",
+			false,
+			"https://example.com/page/2",
+		},
+		{
+			"jsonpath-braces-and-root",
+			ExpressionTypeJsonPath,
+			"{$.items[0].id}",
+			false,
+			"1",
+		},
+		{
+			"jsonpath-filter",
+			ExpressionTypeJsonPath,
+			`{.items[?(@.name=="b")].id}`,
+			false,
+			"2",
+		},
+		{
+			"jsonpath-wildcard",
+			ExpressionTypeJsonPath,
+			"{.items[*].name}",
+			false,
+			"a b c",
+		},
+		{
+			"jsonpath-object",
+			ExpressionTypeJsonPath,
+			"{.items[2].owner}",
+			false,
+			`{"name":"bob"}`,
+		},
+		{
+			"jsonpath-no-match",
+			ExpressionTypeJsonPath,
+			`{.items[?(@.name=="d")].id}`,
+			true,
+			"",
+		},
+		{
+			"jsonpath-missing-key",
+			ExpressionTypeJsonPath,
+			"{.missing}",
+			true,
+			"",
+		},
+		{
+			"jmespath-filter",
+			ExpressionTypeJmesPath,
+			"items[?name=='c'] | [0].owner.name",
+			false,
+			"bob",
+		},
+		{
+			"jmespath-length",
+			ExpressionTypeJmesPath,
+			"length(items)",
+			false,
+			"3",
+		},
+		{
+			"jmespath-wildcard",
+			ExpressionTypeJmesPath,
+			"items[*].id",
+			false,
+			"[1,2,3]",
+		},
+		{
+			"jmespath-flatten",
+			ExpressionTypeJmesPath,
+			"length(items[].tags[])",
+			false,
+			"3",
+		},
+		{
+			"jmespath-no-match",
+			ExpressionTypeJmesPath,
+			"missing",
+			true,
+			"",
+		},
+		{
+			"jmespath-invalid",
+			ExpressionTypeJmesPath,
+			"items[?",
+			true,
+			"",
+		},
+	}
+
+	for _, testdata := range tests {
+		v := &Variable{
+			From:           FromTypeBodyJson,
+			JsonPath:       testdata.Expression,
+			ExpressionType: testdata.ExpressionType,
+		}
+		err := v.parseFromJsonBytes(body)
+		if err == nil && testdata.ExpectErr {
+			t.Errorf("[%s] expected error but got none", testdata.TestName)
+			continue
+		}
+		if err != nil && !testdata.ExpectErr {
+			t.Errorf("[%s] got unexpected err: %s", testdata.TestName, err)
+			continue
+		}
+		if v.Value != testdata.ExpectedValue {
+			t.Errorf("[%s] unexpected value. Got: %s, expected: %s", testdata.TestName, v.Value, testdata.ExpectedValue)
+		}
+	}
+}
+
+func TestValidateExpression(t *testing.T) {
+	if err := validateExpression(ExpressionTypeSlash, "{not checked"); err != nil {
+		t.Errorf("slash expressions should not be validated: %s", err)
+	}
+	if err := validateExpression(ExpressionTypeJsonPath, "{.items[?(@.name=="); err == nil {
+		t.Errorf("expected invalid jsonpath to fail validation")
+	}
+	if err := validateExpression(ExpressionTypeJmesPath, "items[?"); err == nil {
+		t.Errorf("expected invalid jmespath to fail validation")
+	}
+	if err := validateExpression(ExpressionTypeJmesPath, "length(items)"); err != nil {
+		t.Errorf("got unexpected err: %s", err)
+	}
+}
+
+// IDs beyond 2^53 can't be represented exactly as float64
+func TestVariable_parseFromJsonBytesLargeIntegers(t *testing.T) {
+	body := []byte(`{"items": [{"id": 9007199254740993, "count": 2}], "user": {"id": -9007199254740995}}`)
+
+	tests := []struct {
+		TestName       string
+		ExpressionType ExpressionType
+		Expression     string
+		ExpectedValue  string
+	}{
+		{"slash", "", "/items/0/id", "9007199254740993"},
+		{"jsonpath", ExpressionTypeJsonPath, "{.items[0].id}", "9007199254740993"},
+		{"jsonpath-negative", ExpressionTypeJsonPath, "{.user.id}", "-9007199254740995"},
+		{"jmespath", ExpressionTypeJmesPath, "items[0].id", "9007199254740993"},
+		{"jmespath-object", ExpressionTypeJmesPath, "items[0]", `{"count":2,"id":9007199254740993}`},
+		// Smaller numbers are still numbers for JMESPath functions and comparisons
+		{"jmespath-comparison", ExpressionTypeJmesPath, "items[?count > `1`] | length(@)", "1"},
+	}
+
+	for _, testdata := range tests {
+		v := &Variable{Name: "test", ExpressionType: testdata.ExpressionType, JsonPath: testdata.Expression}
+		if err := v.parseFromJsonBytes(body); err != nil {
+			t.Errorf("[%s] got unexpected err: %s", testdata.TestName, err)
+			continue
+		}
+		if v.Value != testdata.ExpectedValue {
+			t.Errorf("[%s] Got: %s, expected: %s", testdata.TestName, v.Value, testdata.ExpectedValue)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"encoding/json"
+	"github.com/ghodss/yaml"
+	"io/ioutil"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+// The status written by the runner must be accepted by the API server
+func TestHttpMonitorStatus_MatchesCRD(t *testing.T) {
+	crdBytes, err := ioutil.ReadFile("../../config/crd/bases/monitoring.raisingthefloor.org_httpmonitors.yaml")
+	if err != nil {
+		t.Fatalf("failed to read CRD: %s", err)
+	}
+	crd := &apiextensionsv1beta1.CustomResourceDefinition{}
+	if err := yaml.Unmarshal(crdBytes, crd); err != nil {
+		t.Fatalf("failed to parse CRD: %s", err)
+	}
+	internalValidation := &apiextensions.CustomResourceValidation{}
+	err = apiextensionsv1beta1.Convert_v1beta1_CustomResourceValidation_To_apiextensions_CustomResourceValidation(crd.Spec.Validation, internalValidation, nil)
+	if err != nil {
+		t.Fatalf("failed to convert CRD validation: %s", err)
+	}
+	validator, _, err := validation.NewSchemaValidator(internalValidation)
+	if err != nil {
+		t.Fatalf("failed to create validator: %s", err)
+	}
+
+	monitor := &HttpMonitor{
+		TypeMeta:   metav1.TypeMeta{APIVersion: GroupVersion.String(), Kind: "HttpMonitor"},
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: HttpMonitorSpec{
+			Period:   &metav1.Duration{Duration: time.Minute},
+			Requests: []HttpRequest{{Name: "check", Method: "GET", Url: "http://test.com"}},
+		},
+	}
+	monitor.Status.RecordExecution(1, &ExecutionResult{
+		StartTime: time.Now(),
+		Requests:  []RequestStatus{newRequestStatus("check", time.Now(), nil, nil)},
+	})
+	if monitor.Status.LastFailure != nil {
+		t.Fatalf("expected a success-only status")
+	}
+
+	// Validate what would be sent to the API server, not the Go struct
+	var serialized map[string]interface{}
+	monitorBytes, _ := json.Marshal(monitor)
+	if err := json.Unmarshal(monitorBytes, &serialized); err != nil {
+		t.Fatalf("failed to serialize monitor: %s", err)
+	}
+	if errs := validation.ValidateCustomResource(nil, serialized, validator); len(errs) != 0 {
+		t.Errorf("status rejected by the CRD schema: %s", errs.ToAggregate())
+	}
+
+	// A first run that was skipped only has a few fields
+	skipped := monitor.DeepCopy()
+	skipped.Status = HttpMonitorStatus{}
+	skipped.Status.RecordSkipped(1, time.Now(), "outside of active_windows")
+	monitorBytes, _ = json.Marshal(skipped)
+	serialized = nil
+	if err := json.Unmarshal(monitorBytes, &serialized); err != nil {
+		t.Fatalf("failed to serialize monitor: %s", err)
+	}
+	if errs := validation.ValidateCustomResource(nil, serialized, validator); len(errs) != 0 {
+		t.Errorf("skipped status rejected by the CRD schema: %s", errs.ToAggregate())
+	}
+}
@@ -0,0 +1,207 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"errors"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+// The outcome of a single run of HttpMonitor.Execute
+// +kubebuilder:object:generate=false
+type ExecutionResult struct {
+	StartTime time.Time
+	Requests  []RequestStatus
+	Cleanup   []RequestStatus
+
+	// Set if the run failed before any request was sent
+	Error string
+}
+
+func firstFailure(statuses []RequestStatus) *RequestStatus {
+	for i := range statuses {
+		if statuses[i].Error != "" {
+			return &statuses[i]
+		}
+	}
+	return nil
+}
+
+// The first request that failed, or nil if all requests succeeded. Cleanup is not included.
+func (e *ExecutionResult) FirstFailure() *RequestStatus {
+	return firstFailure(e.Requests)
+}
+
+func (e *ExecutionResult) Failed() bool {
+	return e.Error != "" || e.FirstFailure() != nil
+}
+
+func (e *ExecutionResult) Result() RunResult {
+	if e.Failed() {
+		return RunResultFailed
+	}
+	return RunResultSucceeded
+}
+
+func (e *ExecutionResult) CleanupFailed() bool {
+	return firstFailure(e.Cleanup) != nil
+}
+
+func newSkippedStatus(name, reason string) RequestStatus {
+	now := metav1.Now()
+	return RequestStatus{
+		Name:          name,
+		Skipped:       reason,
+		LastExecution: &now,
+	}
+}
+
+func newRequestStatus(name string, start time.Time, result *RequestResult, err error) RequestStatus {
+	startTime := metav1.NewTime(start)
+	status := RequestStatus{
+		Name:          name,
+		LastExecution: &startTime,
+	}
+	if result != nil {
+		if result.Response != nil {
+			status.StatusCode = result.Response.StatusCode
+		}
+		status.Duration = result.Timings.Total.String()
+		if result.TLS != nil && len(result.TLS.Certificates) > 0 {
+			expiry := metav1.NewTime(result.TLS.EarliestExpiry().NotAfter)
+			status.CertificateExpiry = &expiry
+		}
+		if result.TLS != nil {
+			status.TLS = result.TLS.status()
+		}
+	}
+	if err != nil {
+		status.Error = err.Error()
+		var assertionErr *AssertionError
+		if errors.As(err, &assertionErr) {
+			status.FailedAssertion = assertionErr.Assertion
+		}
+	}
+	return status
+}
+
+func (s *HttpMonitorStatus) GetCondition(conditionType HttpMonitorConditionType) *HttpMonitorCondition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// Set a condition. The transition time only changes if the status changes.
+func (s *HttpMonitorStatus) SetCondition(conditionType HttpMonitorConditionType, status corev1.ConditionStatus, reason, message string) {
+	existing := s.GetCondition(conditionType)
+	if existing == nil {
+		s.Conditions = append(s.Conditions, HttpMonitorCondition{
+			Type:               conditionType,
+			Status:             status,
+			LastTransitionTime: metav1.Now(),
+			Reason:             reason,
+			Message:            message,
+		})
+		return
+	}
+	if existing.Status != status {
+		existing.Status = status
+		existing.LastTransitionTime = metav1.Now()
+	}
+	existing.Reason = reason
+	existing.Message = message
+}
+
+// True if the Healthy condition is set and true
+func (s *HttpMonitorStatus) IsHealthy() bool {
+	condition := s.GetCondition(HttpMonitorConditionHealthy)
+	return condition != nil && condition.Status == corev1.ConditionTrue
+}
+
+// Update the status with the results of a run
+func (s *HttpMonitorStatus) RecordExecution(generation int64, result *ExecutionResult) {
+	startTime := metav1.NewTime(result.StartTime)
+
+	s.ObservedGeneration = generation
+	s.LastExecution = &startTime
+	s.Requests = result.Requests
+	s.Cleanup = result.Cleanup
+
+	s.SetCondition(HttpMonitorConditionReady, corev1.ConditionTrue, "Running", "monitor is being executed")
+
+	failure := result.FirstFailure()
+	healthy := !result.Failed()
+	s.Healthy = &healthy
+	s.LastResult = result.Result()
+	s.SkipReason = ""
+
+	if result.Error != "" {
+		s.FailingRequest = ""
+		s.LastError = result.Error
+		s.LastFailure = &startTime
+		s.ConsecutiveFailures++
+		s.ConsecutiveSuccesses = 0
+		s.SetCondition(HttpMonitorConditionHealthy, corev1.ConditionFalse, "ExecutionFailed", result.Error)
+	} else if failure != nil {
+		s.FailingRequest = failure.Name
+		s.LastError = failure.Error
+		s.LastFailure = &startTime
+		s.ConsecutiveFailures++
+		s.ConsecutiveSuccesses = 0
+		s.SetCondition(HttpMonitorConditionHealthy, corev1.ConditionFalse, "RequestFailed",
+			fmt.Sprintf("%s: %s", failure.Name, failure.Error))
+	} else {
+		s.FailingRequest = ""
+		s.LastError = ""
+		s.ConsecutiveSuccesses++
+		s.ConsecutiveFailures = 0
+		s.SetCondition(HttpMonitorConditionHealthy, corev1.ConditionTrue, "RequestsSucceeded", "all requests succeeded")
+	}
+
+	if cleanupFailure := firstFailure(result.Cleanup); cleanupFailure != nil && healthy {
+		s.SetCondition(HttpMonitorConditionDegraded, corev1.ConditionTrue, "CleanupFailed",
+			fmt.Sprintf("%s: %s", cleanupFailure.Name, cleanupFailure.Error))
+	} else {
+		s.SetCondition(HttpMonitorConditionDegraded, corev1.ConditionFalse, "AsExpected", "")
+	}
+}
+
+// Update the status with a skipped run. The results of the latest run that executed are kept.
+func (s *HttpMonitorStatus) RecordSkipped(generation int64, when time.Time, reason string) {
+	skipped := metav1.NewTime(when)
+
+	s.ObservedGeneration = generation
+	s.LastSkipped = &skipped
+	s.LastResult = RunResultSkipped
+	s.SkipReason = reason
+
+	s.SetCondition(HttpMonitorConditionReady, corev1.ConditionTrue, "Skipped", reason)
+}
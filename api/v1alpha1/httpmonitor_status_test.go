@@ -0,0 +1,137 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"errors"
+	corev1 "k8s.io/api/core/v1"
+	"testing"
+	"time"
+)
+
+func TestHttpMonitorStatus_RecordExecution(t *testing.T) {
+	status := &HttpMonitorStatus{}
+
+	success := &ExecutionResult{
+		StartTime: time.Now(),
+		Requests: []RequestStatus{
+			newRequestStatus("create user", time.Now(), nil, nil),
+		},
+	}
+	failure := &ExecutionResult{
+		StartTime: time.Now(),
+		Requests: []RequestStatus{
+			newRequestStatus("create user", time.Now(), nil, &AssertionError{Assertion: "user is active", Message: "nope"}),
+		},
+	}
+	cleanupFailure := &ExecutionResult{
+		StartTime: time.Now(),
+		Requests:  success.Requests,
+		Cleanup: []RequestStatus{
+			newRequestStatus("delete user", time.Now(), nil, errors.New("connection refused")),
+		},
+	}
+
+	status.RecordExecution(2, success)
+	status.RecordExecution(2, success)
+	if status.ConsecutiveSuccesses != 2 || status.ConsecutiveFailures != 0 {
+		t.Errorf("unexpected counts after successes: %d/%d", status.ConsecutiveSuccesses, status.ConsecutiveFailures)
+	}
+	if !status.IsHealthy() {
+		t.Errorf("expected healthy after success")
+	}
+	if status.ObservedGeneration != 2 {
+		t.Errorf("unexpected observed generation: %d", status.ObservedGeneration)
+	}
+	if status.LastFailure != nil {
+		t.Errorf("unexpected last failure: %v", status.LastFailure)
+	}
+	healthyTransition := status.GetCondition(HttpMonitorConditionHealthy).LastTransitionTime
+
+	status.RecordExecution(2, failure)
+	if status.ConsecutiveSuccesses != 0 || status.ConsecutiveFailures != 1 {
+		t.Errorf("unexpected counts after failure: %d/%d", status.ConsecutiveSuccesses, status.ConsecutiveFailures)
+	}
+	if status.IsHealthy() {
+		t.Errorf("expected unhealthy after failure")
+	}
+	if status.LastFailure == nil {
+		t.Errorf("expected last failure to be set")
+	}
+	if status.FailingRequest != "create user" {
+		t.Errorf("unexpected failing request: %s", status.FailingRequest)
+	}
+	if status.Requests[0].FailedAssertion != "user is active" {
+		t.Errorf("unexpected failed assertion: %s", status.Requests[0].FailedAssertion)
+	}
+
+	status.RecordExecution(2, cleanupFailure)
+	if !status.IsHealthy() || status.Healthy == nil || !*status.Healthy {
+		t.Errorf("a cleanup failure should not make the monitor unhealthy")
+	}
+	if status.FailingRequest != "" {
+		t.Errorf("expected failing request to be cleared, got: %s", status.FailingRequest)
+	}
+	if status.GetCondition(HttpMonitorConditionDegraded).Status != corev1.ConditionTrue {
+		t.Errorf("expected degraded after a cleanup failure")
+	}
+	if status.GetCondition(HttpMonitorConditionHealthy).LastTransitionTime.Before(&healthyTransition) {
+		t.Errorf("expected the healthy transition time to move forward")
+	}
+	if len(status.Conditions) != 3 {
+		t.Errorf("unexpected number of conditions: %d", len(status.Conditions))
+	}
+}
+
+func TestHttpMonitorStatus_RecordSkipped(t *testing.T) {
+	status := &HttpMonitorStatus{}
+	status.RecordExecution(1, &ExecutionResult{
+		StartTime: time.Now(),
+		Requests:  []RequestStatus{newRequestStatus("create user", time.Now(), nil, nil)},
+	})
+	if status.LastResult != RunResultSucceeded {
+		t.Errorf("unexpected last result: %s", status.LastResult)
+	}
+
+	status.RecordSkipped(2, time.Now(), "in blackout window 'maintenance'")
+	if status.LastResult != RunResultSkipped || status.SkipReason != "in blackout window 'maintenance'" {
+		t.Errorf("unexpected skip: %s, %s", status.LastResult, status.SkipReason)
+	}
+	if status.LastSkipped == nil {
+		t.Errorf("expected last skipped to be set")
+	}
+	// Nothing was checked, so the latest results are kept
+	if !status.IsHealthy() || status.ConsecutiveSuccesses != 1 || len(status.Requests) != 1 {
+		t.Errorf("expected the results of the latest run to be kept")
+	}
+	if condition := status.GetCondition(HttpMonitorConditionReady); condition.Reason != "Skipped" {
+		t.Errorf("unexpected ready condition: %v", condition)
+	}
+
+	status.RecordExecution(2, &ExecutionResult{StartTime: time.Now(), Error: "failed to load environment"})
+	if status.LastResult != RunResultFailed || status.SkipReason != "" {
+		t.Errorf("unexpected result after a failed run: %s, %s", status.LastResult, status.SkipReason)
+	}
+}
@@ -29,6 +29,7 @@ The R&D leading to these results received funding from the:
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"net/http"
 	"net/url"
@@ -45,6 +46,25 @@ var (
 	FromTypeBodyRaw  FromType = "body_raw" //
 	FromTypeHeaders  FromType = "headers"  // extract the variable from Headers
 	FromTypeProvided FromType = "provided" // provided by the user
+
+	FromTypeBodyRegex    FromType = "body_regex"    // a capture group of `regex`
+	FromTypeBodyXml      FromType = "body_xml"      // an `xpath` expression
+	FromTypeBodyHtml     FromType = "body_html"     // an `xpath` expression or CSS `selector`
+	FromTypeCookie       FromType = "cookie"        // a cookie set by the response, named by `json_path`, ex: /session
+	FromTypeStatusCode   FromType = "status_code"   // the response status code
+	FromTypeResponseTime FromType = "response_time" // the total request duration in milliseconds
+	FromTypeFinalUrl     FromType = "final_url"     // the url of the response, after following redirects
+
+	// Only valid for assertions: the size of the response body in bytes
+	FromTypeResponseSize FromType = "response_size"
+)
+
+type ExpressionType string
+
+var (
+	ExpressionTypeSlash    ExpressionType = "slash"    // slash separated keys and indexes, ex: /items/0/id
+	ExpressionTypeJsonPath ExpressionType = "jsonpath" // kubernetes style JSONPath, ex: {.items[?(@.name=="x")].id}
+	ExpressionTypeJmesPath ExpressionType = "jmespath" // JMESPath, ex: items[?name=='x'] | [0].id
 )
 
 type Variable struct {
@@ -52,18 +72,293 @@ type Variable struct {
 	Name string `json:"name"`
 
 	// Where to extract the variable from
-	// +kubebuilder:validation:Enum=body_yaml;body_json;body_raw;headers;provided
+	// +kubebuilder:validation:Enum=body_yaml;body_json;body_raw;headers;provided;body_regex;body_xml;body_html;cookie;status_code;response_time;final_url
 	From FromType `json:"from"`
 
 	// The JSON path to the data.
 	JsonPath string `json:"json_path,omitempty"`
 
+	// How `json_path` is evaluated for body_json and body_yaml. Default is slash.
+	// +kubebuilder:validation:Enum=slash;jsonpath;jmespath
+	ExpressionType ExpressionType `json:"expression_type,omitempty"`
+
+	// The regular expression for body_regex, ex: `name="csrf" value="([^"]+)"`
+	Regex string `json:"regex,omitempty"`
+
+	// The capture group of `regex`, by name or number. Default is 1, or the whole match if `regex` has no groups.
+	Group string `json:"group,omitempty"`
+
+	// The XPath expression for body_xml and body_html, ex: `//input[@name='csrf']/@value` or `count(//item)`
+	XPath string `json:"xpath,omitempty"`
+
+	// The CSS selector for body_html, ex: `input[name=csrf]`. Uses the first matching element.
+	Selector string `json:"selector,omitempty"`
+
+	// Read this attribute of the element matched by `selector` instead of its text
+	Attribute string `json:"attribute,omitempty"`
+
 	// The final value of the variable, after its been extracted
 	Value string `json:"value"`
+
+	// Secret values are redacted from logs and status
+	Secret bool `json:"-"`
 }
 
 type VariableList []*Variable
 
+type AssertionOperator string
+
+var (
+	AssertionOperatorEquals      AssertionOperator = "equals"
+	AssertionOperatorNotEquals   AssertionOperator = "not_equals"
+	AssertionOperatorContains    AssertionOperator = "contains"
+	AssertionOperatorRegex       AssertionOperator = "regex"
+	AssertionOperatorExists      AssertionOperator = "exists"
+	AssertionOperatorNotExists   AssertionOperator = "not_exists"
+	AssertionOperatorLessThan    AssertionOperator = "less_than"
+	AssertionOperatorGreaterThan AssertionOperator = "greater_than"
+)
+
+// A check against the response. A failed assertion fails the request.
+type Assertion struct {
+	// Name of the assertion. Used for debugging and metrics. Defaults to a description of the assertion.
+	Name string `json:"name,omitempty"`
+
+	// Where to extract the actual value from
+	// +kubebuilder:validation:Enum=body_yaml;body_json;body_raw;headers;response_size;cookie;status_code;response_time;final_url
+	From FromType `json:"from"`
+
+	// The JSON path to the data, or the cookie name. Same format as variables.
+	JsonPath string `json:"json_path,omitempty"`
+
+	// How `json_path` is evaluated for body_json and body_yaml. Default is slash.
+	// +kubebuilder:validation:Enum=slash;jsonpath;jmespath
+	ExpressionType ExpressionType `json:"expression_type,omitempty"`
+
+	// How to compare the actual value with `value`. Default is "equals"
+	// +kubebuilder:validation:Enum=equals;not_equals;contains;regex;exists;not_exists;less_than;greater_than
+	Operator AssertionOperator `json:"operator,omitempty"`
+
+	// The expected value. Unused for "exists" and "not_exists"
+	Value string `json:"value,omitempty"`
+}
+
+// HTTP basic authentication. Credentials are read from Secrets.
+type BasicAuth struct {
+	Username corev1.SecretKeySelector `json:"username"`
+	Password corev1.SecretKeySelector `json:"password"`
+}
+
+// A static bearer token, read from a Secret
+type BearerAuth struct {
+	Token corev1.SecretKeySelector `json:"token"`
+}
+
+// The OAuth2 client credentials flow. Tokens are cached and refreshed across runs.
+type OAuth2ClientCredentials struct {
+	// The token endpoint
+	TokenUrl string `json:"token_url"`
+
+	ClientId     corev1.SecretKeySelector `json:"client_id"`
+	ClientSecret corev1.SecretKeySelector `json:"client_secret"`
+
+	// Optional scopes to request
+	Scopes []string `json:"scopes,omitempty"`
+
+	// Optional additional parameters for the token request
+	EndpointParams map[string]string `json:"endpoint_params,omitempty"`
+}
+
+// Adds an Authorization header to requests. Only one method may be set.
+type Auth struct {
+	Basic  *BasicAuth               `json:"basic,omitempty"`
+	Bearer *BearerAuth              `json:"bearer,omitempty"`
+	OAuth2 *OAuth2ClientCredentials `json:"oauth2,omitempty"`
+}
+
+// TLS settings used when connecting to HTTPS endpoints
+type TLSConfig struct {
+	// PEM encoded CA bundle used to verify servers, in addition to the system roots
+	CA *VariableSource `json:"ca,omitempty"`
+
+	// PEM encoded client certificate for mutual TLS. Requires `client_key`.
+	ClientCert *corev1.SecretKeySelector `json:"client_cert,omitempty"`
+
+	// PEM encoded client key for mutual TLS. Requires `client_cert`.
+	ClientKey *corev1.SecretKeySelector `json:"client_key,omitempty"`
+
+	// Overrides the server name used to verify the certificate and for SNI
+	ServerName string `json:"server_name,omitempty"`
+
+	// The minimum TLS version. Defaults to the go default.
+	// +kubebuilder:validation:Enum="1.0";"1.1";"1.2";"1.3"
+	MinVersion string `json:"min_version,omitempty"`
+
+	// Skip verification of the server certificate. Only use this for testing.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// How requests are sent. Monitors with the same settings share connections.
+type ClientConfig struct {
+	// HTTP proxy used for all requests, ex: "http://proxy.example.com:3128".
+	// Defaults to the proxy from the controller's environment.
+	ProxyUrl string `json:"proxy_url,omitempty"`
+
+	// Whether to follow redirects. Default is true. If false, the redirect response itself is checked.
+	FollowRedirects *bool `json:"follow_redirects,omitempty"`
+
+	// The maximum number of redirects to follow. Default is 10
+	MaxRedirects int `json:"max_redirects,omitempty"`
+
+	// Use a fresh connection for every request, so each run measures DNS, connect and TLS handshake
+	DisableKeepAlives bool `json:"disable_keep_alives,omitempty"`
+
+	// Force HTTP/1.1 or HTTP/2. With "http2", responses over other protocols are failures.
+	// +kubebuilder:validation:Enum=http1;http2
+	Protocol string `json:"protocol,omitempty"`
+
+	// The User-Agent header for requests that do not set one
+	UserAgent string `json:"user_agent,omitempty"`
+}
+
+// Cookies set by responses are sent with later requests
+type CookieJarConfig struct {
+	// Keep cookies between runs. By default, every run starts with an empty jar.
+	PersistAcrossRuns bool `json:"persist_across_runs,omitempty"`
+}
+
+// Send a request once for every item of a JSON array
+type ForEach struct {
+	// The variable holding the JSON array, ex: extracted with `expression_type: jsonpath`
+	Variable string `json:"variable"`
+
+	// The variable holding the current item. Default is "item". Objects are JSON encoded, and their
+	// fields are also available as `{item.field}`. The index of the item is available as `{item_index}`.
+	As string `json:"as,omitempty"`
+
+	// The maximum number of items. Items beyond it are ignored. Default is 100
+	// +kubebuilder:validation:Minimum=1
+	Limit int `json:"limit,omitempty"`
+}
+
+// Send a request again for every following page. `query_params` are only used for the first page.
+type Paginate struct {
+	// Where to find the url of the next page. Pagination stops when it is missing or empty.
+	// Relative urls are resolved against the current page.
+	// +kubebuilder:validation:Enum=body_yaml;body_json;body_raw;headers;body_regex;body_xml;body_html
+	From FromType `json:"from"`
+
+	// The path to the next url. Same format as variables.
+	JsonPath string `json:"json_path,omitempty"`
+
+	// How `json_path` is evaluated. Same as variables.
+	// +kubebuilder:validation:Enum=slash;jsonpath;jmespath
+	ExpressionType ExpressionType `json:"expression_type,omitempty"`
+
+	// The regular expression for body_regex. The first capture group is used.
+	Regex string `json:"regex,omitempty"`
+
+	// The XPath expression for body_xml and body_html
+	XPath string `json:"xpath,omitempty"`
+
+	// The maximum number of pages, including the first. Default is 10
+	// +kubebuilder:validation:Minimum=1
+	MaxPages int `json:"max_pages,omitempty"`
+}
+
+type OnFailure string
+
+const (
+	OnFailureCleanup  OnFailure = "cleanup"  // skip the remaining requests and run cleanup
+	OnFailureStop     OnFailure = "stop"     // skip the remaining requests and cleanup
+	OnFailureContinue OnFailure = "continue" // run the remaining requests anyway. The run still counts as failed.
+)
+
+type UnresolvedPolicy string
+
+const (
+	UnresolvedPolicySend  UnresolvedPolicy = "send"  // send `{name}` placeholders of undefined variables as they are
+	UnresolvedPolicySkip  UnresolvedPolicy = "skip"  // skip the request. Skipped requests are not failures.
+	UnresolvedPolicyError UnresolvedPolicy = "error" // fail the request without sending it
+)
+
+// What a request needs from earlier requests of the run. If anything is missing, the request is skipped.
+type DependsOn struct {
+	// Variables that must be defined and not empty
+	Variables []string `json:"variables,omitempty"`
+
+	// Requests that must have succeeded earlier in the run
+	Requests []string `json:"requests,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=mon;tue;wed;thu;fri;sat;sun
+type Weekday string
+
+// A window of time that repeats every day, or on some days of the week, in the monitor's `time_zone`
+type TimeWindow struct {
+	// Describes the window in skipped runs, ex: nightly database maintenance
+	Name string `json:"name,omitempty"`
+
+	// The days the window starts on. Default is every day.
+	Days []Weekday `json:"days,omitempty"`
+
+	// When the window starts, as HH:MM, ex: "02:00"
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	Start string `json:"start"`
+
+	// When the window ends, as HH:MM, ex: "03:30". A window that ends before it starts ends on the next day.
+	// A window that ends when it starts lasts the whole day.
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	End string `json:"end"`
+}
+
+type TemplateEngine string
+
+const (
+	TemplateEngineSimple TemplateEngine = "simple" // replace `{name}` with the variable's value
+	TemplateEngineGo     TemplateEngine = "go"     // go text/template, ex: `{{ .token | b64enc }}`, followed by `{name}` replacement
+)
+
+type BackoffType string
+
+const (
+	BackoffTypeFixed       BackoffType = "fixed"       // wait `delay` between attempts
+	BackoffTypeExponential BackoffType = "exponential" // double the delay after every attempt, up to `max_delay`
+)
+
+type RetryCondition string
+
+const (
+	RetryConditionConnectionError RetryCondition = "connection_error" // no response was received
+	RetryConditionTimeout         RetryCondition = "timeout"          // the request timed out
+)
+
+// When and how often a failed request is retried before it counts as a failure
+type RetryPolicy struct {
+	// The maximum number of attempts, including the first. Default is 1, which disables retries.
+	// +kubebuilder:validation:Minimum=1
+	MaxAttempts int `json:"max_attempts,omitempty"`
+
+	// Default is fixed
+	// +kubebuilder:validation:Enum=fixed;exponential
+	Backoff BackoffType `json:"backoff,omitempty"`
+
+	// The delay before the first retry. Default is 1s
+	Delay string `json:"delay,omitempty"`
+
+	// The longest delay between attempts with exponential backoff. Default is 30s
+	MaxDelay string `json:"max_delay,omitempty"`
+
+	// Randomize each delay between half and all of its value
+	Jitter bool `json:"jitter,omitempty"`
+
+	// Which failures are retried. Default is connection_error and timeout.
+	RetryOn []RetryCondition `json:"retry_on,omitempty"`
+
+	// Unexpected response codes that are retried, ex: [502, 503, 504]
+	RetryOnStatusCodes []int `json:"retry_on_status_codes,omitempty"`
+}
+
 type HttpRequest struct {
 	// Name of the HTTP request. Used for debugging and metrics
 	Name string `json:"name"`
@@ -74,6 +369,14 @@ type HttpRequest struct {
 	// The request timeout. Default is 5 seconds
 	Timeout string `json:"timeout,omitempty"`
 
+	// Optional maximum total duration of the request. Slower responses are counted as failures,
+	// even if the response was otherwise successful.
+	MaxLatency string `json:"max_latency,omitempty"`
+
+	// Optional minimum remaining validity of every certificate presented by the server, ex: "336h".
+	// The request fails if any certificate expires sooner.
+	MinCertValidity string `json:"min_cert_validity,omitempty"`
+
 	// The HTTP method
 	// +kubebuilder:validation:Enum=HEAD;GET;POST;PUT;PATCH;DELETE;OPTIONS
 	Method string `json:"method"`
@@ -90,14 +393,94 @@ type HttpRequest struct {
 	// Request headers
 	Headers http.Header `json:"headers,omitempty"`
 
+	// Authentication for this request. Overrides the monitor's `auth`. Set to `{}` to disable it.
+	Auth *Auth `json:"auth,omitempty"`
+
+	// Retries for this request. Overrides the monitor's `retry`. Set to `{}` to disable it.
+	Retry *RetryPolicy `json:"retry,omitempty"`
+
+	// Consecutive requests with the same group run in parallel, up to the monitor's `max_concurrency`.
+	// Requests in a group only see variables extracted before the group. Their variables are available
+	// after the whole group completes. Two requests in a group cannot extract the same variable.
+	// If requests in a group fail, the `on_failure` of the first failed request in the list is used.
+	// Not allowed in `cleanup`.
+	ParallelGroup string `json:"parallel_group,omitempty"`
+
+	// Send the request once for every item of an array. Metrics and status are recorded under this request's name.
+	ForEach *ForEach `json:"for_each,omitempty"`
+
+	// Follow the next page links of the response. Metrics and status are recorded under this request's name.
+	Paginate *Paginate `json:"paginate,omitempty"`
+
+	// Only run the request if this go template expression is true, ex: `not (defined "token")` or
+	// `eq (status "get user") 404`. Besides the template functions, `defined`, `status`, `succeeded`,
+	// `failed` and `skipped` check variables and earlier requests of the run. Skipped requests are not failures.
+	When string `json:"when,omitempty"`
+
+	// What to do if this request fails. Default is cleanup. Ignored for cleanup requests, which always continue.
+	// +kubebuilder:validation:Enum=cleanup;stop;continue
+	OnFailure OnFailure `json:"on_failure,omitempty"`
+
+	// Skip the request unless these variables and requests are available, ex: a cleanup DELETE that
+	// needs the id from a create request.
+	DependsOn *DependsOn `json:"depends_on,omitempty"`
+
+	// What to do if the url, body, headers or query params contain `{name}` placeholders of undefined
+	// variables. Overrides the monitor's `on_unresolved`.
+	// +kubebuilder:validation:Enum=send;skip;error
+	OnUnresolved UnresolvedPolicy `json:"on_unresolved,omitempty"`
+
+	// How variables are substituted into the url, body, headers and query params. Overrides the monitor's `template_engine`.
+	// +kubebuilder:validation:Enum=simple;go
+	TemplateEngine TemplateEngine `json:"template_engine,omitempty"`
+
 	// Extract variables for later requests to utilize
 	VariablesFromResponse VariableList `json:"vars_from_response,omitempty"`
 
 	// Expected response codes. By default, this will be anything seen as "ok"
 	ExpectedResponseCodes []int `json:"expected_response_codes,omitempty"`
 
+	// Checks against the response body, headers or size. All must pass for the request to succeed.
+	Assertions []Assertion `json:"assertions,omitempty"`
+
 	// VariablesFromResponse available from previous requests
 	AvailableVariables VariableList `json:"-"`
+
+	// Headers resolved from `auth`. An explicit Authorization header takes precedence.
+	AuthHeaders http.Header `json:"-"`
+
+	// Fail go templates that use undefined variables, instead of rendering them empty. Set from `on_unresolved`.
+	FailOnUnresolved bool `json:"-"`
+}
+
+// A value read from a Secret or ConfigMap in the same namespace as the monitor
+type VariableSource struct {
+	// Selects a key of a Secret. The value is redacted from logs and status.
+	SecretKeyRef *corev1.SecretKeySelector `json:"secret_key_ref,omitempty"`
+
+	// Selects a key of a ConfigMap
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"config_map_key_ref,omitempty"`
+}
+
+// A single variable whose value is read from a Secret or ConfigMap
+type EnvironmentVariable struct {
+	// The variable name
+	Name string `json:"name"`
+
+	// Where to read the value from
+	ValueFrom VariableSource `json:"value_from"`
+}
+
+// Every key of a Secret or ConfigMap becomes a variable
+type EnvironmentFromSource struct {
+	// Optional prefix added to every variable name
+	Prefix string `json:"prefix,omitempty"`
+
+	// The Secret to read. Values are redacted from logs and status.
+	SecretRef *corev1.SecretEnvSource `json:"secret_ref,omitempty"`
+
+	// The ConfigMap to read
+	ConfigMapRef *corev1.ConfigMapEnvSource `json:"config_map_ref,omitempty"`
 }
 
 // HttpMonitorSpec defines the desired state of HttpMonitor
@@ -108,13 +491,179 @@ type HttpMonitorSpec struct {
 	// Variables available to all requests from the start
 	Environment map[string]string `json:"environment,omitempty"`
 
+	// Variables read from single keys of Secrets or ConfigMaps at execution time
+	EnvironmentRefs []EnvironmentVariable `json:"environment_refs,omitempty"`
+
+	// Variables read from all keys of Secrets or ConfigMaps at execution time
+	EnvFrom []EnvironmentFromSource `json:"env_from,omitempty"`
+
+	// Default authentication for all requests
+	Auth *Auth `json:"auth,omitempty"`
+
+	// TLS settings for all requests
+	TLS *TLSConfig `json:"tls,omitempty"`
+
+	// HTTP client settings for all requests
+	Client *ClientConfig `json:"client,omitempty"`
+
+	// Default retries for all requests
+	Retry *RetryPolicy `json:"retry,omitempty"`
+
+	// Carry cookies from one request to the next, like a browser. Set to `{}` to enable it.
+	// Cookies in the jar are available to `cookie` variables.
+	CookieJar *CookieJarConfig `json:"cookie_jar,omitempty"`
+
+	// How variables are substituted into requests. Default is simple.
+	// With "go", requests are go templates with the functions b64enc, urlquery, toJson, now, unixMillis,
+	// uuid, randInt, hmacSha256, sha256, default and env. Variables are available as fields, ex: `{{ .token }}`.
+	// +kubebuilder:validation:Enum=simple;go
+	TemplateEngine TemplateEngine `json:"template_engine,omitempty"`
+
+	// Strict mode fails requests that contain `{name}` placeholders of undefined variables, unless `on_unresolved`
+	// says otherwise, and the validating webhook rejects placeholders that no variable defines.
+	// With webhooks enabled, new monitors are strict unless this is set to false.
+	Strict *bool `json:"strict,omitempty"`
+
+	// What to do with requests that contain `{name}` placeholders of undefined variables.
+	// Default is skip for cleanup requests. For other requests, error in strict mode, send otherwise.
+	// +kubebuilder:validation:Enum=send;skip;error
+	OnUnresolved UnresolvedPolicy `json:"on_unresolved,omitempty"`
+
+	// The maximum number of requests of a `parallel_group` sent at the same time. Default is 4
+	// +kubebuilder:validation:Minimum=1
+	MaxConcurrency int `json:"max_concurrency,omitempty"`
+
 	Requests []HttpRequest `json:"requests"`
 
 	// Optional requests to be run after `requests`.
 	Cleanup []HttpRequest `json:"cleanup,omitempty"`
 
-	// How frequently to execute the monitor requests
-	Period *metav1.Duration `json:"period"`
+	// How frequently to execute the monitor requests. Either period or schedule is required.
+	Period *metav1.Duration `json:"period,omitempty"`
+
+	// When to execute the monitor requests, as a cron expression, ex: "*/5 * * * *" or "@hourly".
+	// Either period or schedule is required.
+	Schedule string `json:"schedule,omitempty"`
+
+	// The IANA time zone of `schedule`, `active_windows` and `blackout_windows`, ex: America/Los_Angeles.
+	// Default is UTC.
+	TimeZone string `json:"time_zone,omitempty"`
+
+	// Only execute the monitor during these windows. Runs outside of them are skipped. Default is always.
+	ActiveWindows []TimeWindow `json:"active_windows,omitempty"`
+
+	// Never execute the monitor during these windows, ex: during database maintenance. Runs in them are skipped.
+	BlackoutWindows []TimeWindow `json:"blackout_windows,omitempty"`
+
+	// Execute the monitor as soon as it is created or changed, instead of waiting for the first period.
+	// Default is the controller's --run-immediately.
+	RunImmediately *bool `json:"run_immediately,omitempty"`
+
+	// Delay the first run by a random duration up to this, ex: 30s, so monitors that start at the same time,
+	// like after a controller rollout, don't all run at once. Default is the controller's --initial-jitter.
+	InitialJitter string `json:"initial_jitter,omitempty"`
+
+	// The maximum duration of the requests of a run, including retries. Requests still in progress are cancelled
+	// and the remaining requests are skipped. Cleanup requests always run. Default is the period, or the time
+	// until the next run of the schedule.
+	RunTimeout string `json:"run_timeout,omitempty"`
+}
+
+type RunResult string
+
+const (
+	RunResultSucceeded RunResult = "succeeded"
+	RunResultFailed    RunResult = "failed"
+	RunResultSkipped   RunResult = "skipped" // outside of `active_windows`, or in `blackout_windows`
+)
+
+type HttpMonitorConditionType string
+
+var (
+	// The monitor is being executed by the controller
+	HttpMonitorConditionReady HttpMonitorConditionType = "Ready"
+	// All requests succeeded in the latest run
+	HttpMonitorConditionHealthy HttpMonitorConditionType = "Healthy"
+	// The latest run succeeded, but something went wrong along the way (ex: a cleanup request failed)
+	HttpMonitorConditionDegraded HttpMonitorConditionType = "Degraded"
+)
+
+type HttpMonitorCondition struct {
+	// Type of the condition
+	// +kubebuilder:validation:Enum=Ready;Healthy;Degraded
+	Type HttpMonitorConditionType `json:"type"`
+
+	// Status of the condition, one of True, False, Unknown
+	Status corev1.ConditionStatus `json:"status"`
+
+	// Last time the condition changed status
+	LastTransitionTime metav1.Time `json:"last_transition_time,omitempty"`
+
+	// A machine readable reason for the last transition
+	Reason string `json:"reason,omitempty"`
+
+	// A human readable message about the last transition
+	Message string `json:"message,omitempty"`
+}
+
+// The result of the last execution of a single request
+type RequestStatus struct {
+	// Name of the HTTP request
+	Name string `json:"name"`
+
+	// The response status code. Empty if no response was received
+	StatusCode int `json:"status_code,omitempty"`
+
+	// How long the request took
+	Duration string `json:"duration,omitempty"`
+
+	// Why the request failed. Empty on success
+	Error string `json:"error,omitempty"`
+
+	// The name of the assertion that failed, if any
+	FailedAssertion string `json:"failed_assertion,omitempty"`
+
+	// Why the request was skipped. Empty if it ran
+	Skipped string `json:"skipped,omitempty"`
+
+	// How many times the request was sent for `for_each` or `paginate`
+	Iterations int `json:"iterations,omitempty"`
+
+	// How many attempts were made, including retries
+	Attempts int `json:"attempts,omitempty"`
+
+	// When the first certificate presented by the server expires. HTTPS only.
+	CertificateExpiry *metav1.Time `json:"certificate_expiry,omitempty"`
+
+	// The TLS connection and the certificate presented by the server. HTTPS only.
+	TLS *TLSStatus `json:"tls,omitempty"`
+
+	// When the request was executed
+	LastExecution *metav1.Time `json:"last_execution,omitempty"`
+}
+
+// The TLS connection of a request
+type TLSStatus struct {
+	// The negotiated TLS version. 1.0, 1.1, 1.2 or 1.3
+	Version string `json:"version,omitempty"`
+
+	// The negotiated cipher suite, in hex. Ex: 0x1301
+	CipherSuite string `json:"cipher_suite,omitempty"`
+
+	// The server name sent by the client
+	ServerName string `json:"server_name,omitempty"`
+
+	// The protocol negotiated with ALPN. Ex: h2
+	NegotiatedProtocol string `json:"negotiated_protocol,omitempty"`
+
+	// The subject of the leaf certificate
+	Subject string `json:"subject,omitempty"`
+
+	// The issuer of the leaf certificate
+	Issuer string `json:"issuer,omitempty"`
+
+	// The subject alternative names of the leaf certificate
+	DNSNames []string `json:"dns_names,omitempty"`
 }
 
 // HttpMonitorStatus defines the observed state of HttpMonitor
@@ -122,14 +671,62 @@ type HttpMonitorStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	LastExecution *metav1.Time `json:"last_execution"`
-	LastFailure   *metav1.Time `json:"last_failure"`
+	// The generation of the spec used for the latest run
+	ObservedGeneration int64 `json:"observed_generation,omitempty"`
+
+	LastExecution *metav1.Time `json:"last_execution,omitempty"`
+	LastFailure   *metav1.Time `json:"last_failure,omitempty"`
+
+	// Whether all requests succeeded in the latest run
+	Healthy *bool `json:"healthy,omitempty"`
+
+	// The request that failed in the latest run, if any
+	FailingRequest string `json:"failing_request,omitempty"`
+
+	// The error of the failing request in the latest run, if any
+	LastError string `json:"last_error,omitempty"`
+
+	// The result of the latest run: succeeded, failed or skipped
+	// +kubebuilder:validation:Enum=succeeded;failed;skipped
+	LastResult RunResult `json:"last_result,omitempty"`
+
+	// When a run was last skipped because of `active_windows` or `blackout_windows`
+	LastSkipped *metav1.Time `json:"last_skipped,omitempty"`
+
+	// Why the latest run was skipped, if it was
+	SkipReason string `json:"skip_reason,omitempty"`
+
+	// Number of runs in a row that failed
+	// +optional
+	ConsecutiveFailures int `json:"consecutive_failures"`
+
+	// Number of runs in a row that succeeded
+	// +optional
+	ConsecutiveSuccesses int `json:"consecutive_successes"`
+
+	Conditions []HttpMonitorCondition `json:"conditions,omitempty"`
+
+	// Results of each request in the latest run
+	Requests []RequestStatus `json:"requests,omitempty"`
+
+	// Results of each cleanup request in the latest run
+	Cleanup []RequestStatus `json:"cleanup,omitempty"`
 }
 
 // HttpMonitor is the Schema for the httpmonitors API
 // +kubebuilder:object:root=true
 // +k8s:openapi-gen=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Period",type="string",JSONPath=".spec.period"
+// +kubebuilder:printcolumn:name="Schedule",type="string",JSONPath=".spec.schedule",priority=1
+// +kubebuilder:printcolumn:name="Healthy",type="boolean",JSONPath=".status.healthy"
+// +kubebuilder:printcolumn:name="Last Result",type="string",JSONPath=".status.last_result"
+// +kubebuilder:printcolumn:name="Last Run",type="date",JSONPath=".status.last_execution"
+// +kubebuilder:printcolumn:name="Last Failure",type="date",JSONPath=".status.last_failure"
+// +kubebuilder:printcolumn:name="Consecutive Failures",type="integer",JSONPath=".status.consecutive_failures"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:printcolumn:name="Failing Request",type="string",JSONPath=".status.failing_request",priority=1
+// +kubebuilder:printcolumn:name="Error",type="string",JSONPath=".status.last_error",priority=1
 type HttpMonitor struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -28,10 +28,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/go-logr/logr"
+	"github.com/oregondesignservices/monitoring-controller/internal/auth"
+	"github.com/oregondesignservices/monitoring-controller/internal/conf"
 	"github.com/oregondesignservices/monitoring-controller/internal/httpclient"
-	"k8s.io/apimachinery/pkg/util/rand"
 	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptrace"
 	"net/url"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 	"strings"
 	"time"
@@ -39,53 +44,86 @@ import (
 
 var httpMonitorUtilsLogger = logf.Log.WithName("httpmonitor-utils")
 
-func replaceQueryParams(v map[string][]string, replacer *strings.Replacer) url.Values {
+func replaceQueryParams(v map[string][]string, r *renderer) (url.Values, error) {
 	if len(v) == 0 {
-		return v
+		return v, nil
 	}
 	newValues := make(url.Values)
 
 	for key, values := range v {
 		for _, v := range values {
-			newValues.Add(key, replacer.Replace(v))
+			value, err := r.render(v)
+			if err != nil {
+				return nil, fmt.Errorf("query param %s: %w", key, err)
+			}
+			newValues.Add(key, value)
 		}
 	}
 
-	return newValues
+	return newValues, nil
 }
 
-func replaceHeader(v http.Header, replacer *strings.Replacer) http.Header {
+func replaceHeader(v http.Header, r *renderer) (http.Header, error) {
 	if len(v) == 0 {
-		return v
+		return v, nil
 	}
 
 	newHeaders := make(http.Header)
 
 	for key, values := range v {
 		for _, v := range values {
-			newHeaders.Add(key, replacer.Replace(v))
+			value, err := r.render(v)
+			if err != nil {
+				return nil, fmt.Errorf("header %s: %w", key, err)
+			}
+			newHeaders.Add(key, value)
 		}
 	}
 
-	return newHeaders
+	return newHeaders, nil
 }
 
-func (r *HttpRequest) BuildRequest() (*http.Request, error) {
-	replacer := r.AvailableVariables.newReplacer()
+func (r *HttpRequest) BuildRequest(ctx context.Context) (*http.Request, error) {
+	renderer := newRenderer(r.AvailableVariables, r.TemplateEngine, r.FailOnUnresolved)
 
-	finalUrl := replacer.Replace(r.Url)
-	body := replacer.Replace(r.Body)
-	query := replaceQueryParams(r.QueryParams, replacer)
-	header := replaceHeader(r.Headers, replacer)
+	finalUrl, err := renderer.render(r.Url)
+	if err != nil {
+		return nil, fmt.Errorf("url: %w", err)
+	}
+	body, err := renderer.render(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("body: %w", err)
+	}
+	query, err := replaceQueryParams(r.QueryParams, renderer)
+	if err != nil {
+		return nil, err
+	}
+	header, err := replaceHeader(r.Headers, renderer)
+	if err != nil {
+		return nil, err
+	}
 
-	req, err := http.NewRequest(r.Method, finalUrl, strings.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, r.Method, finalUrl, strings.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
 
+	// The client adds cookies and other headers, so this must not be nil
+	if header == nil {
+		header = make(http.Header)
+	}
+	for key, values := range r.AuthHeaders {
+		if header.Get(key) != "" {
+			continue
+		}
+		header[key] = values
+	}
 	req.Header = header
 
-	req.URL.RawQuery = query.Encode()
+	// Keep any query in the url itself unless query params are set
+	if len(query) > 0 {
+		req.URL.RawQuery = query.Encode()
+	}
 	return req, nil
 }
 
@@ -98,46 +136,94 @@ func containsInt(needle int, haystay []int) bool {
 	return false
 }
 
-// Send the HTTP request and parse any variables
-func (r *HttpRequest) sendRequest(client *http.Client) (*http.Response, error) {
-	req, err := r.BuildRequest()
-	if err != nil {
-		return nil, err
+func parseDurationOrDefault(duration string, defaultDuration time.Duration) (time.Duration, error) {
+	if duration == "" {
+		return defaultDuration, nil
 	}
+	return time.ParseDuration(duration)
+}
 
-	timeoutDuration := 5 * time.Second
+// Send the HTTP request and parse any variables. Cancelling ctx aborts the request.
+func (r *HttpRequest) sendRequest(ctx context.Context, client *http.Client) (*RequestResult, error) {
+	result := &RequestResult{}
 
-	if r.Timeout != "" {
-		timeoutDuration, err = time.ParseDuration(r.Timeout)
-		if err != nil {
-			return nil, err
-		}
+	timeoutDuration, err := parseDurationOrDefault(r.Timeout, 5*time.Second)
+	if err != nil {
+		return result, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, timeoutDuration)
 	defer cancel()
 
-	resp, err := client.Do(req.WithContext(ctx))
+	tracer := newTimingTracer(&result.Timings)
+	ctx = httptrace.WithClientTrace(ctx, tracer.clientTrace())
+
+	req, err := r.BuildRequest(ctx)
 	if err != nil {
-		return nil, err
+		return result, err
 	}
-	return resp, r.handleResponse(resp)
+
+	tracer.begin()
+	resp, err := client.Do(req)
+	if err != nil {
+		tracer.done()
+		return result, &TransportError{Err: err}
+	}
+	// Read the whole body up front so the transfer is included in the timings
+	readBodyAndReset(resp)
+	tracer.done()
+	result.Response = resp
+	if resp.TLS != nil {
+		result.TLS = newTLSInfo(resp.TLS)
+	}
+	if client.Jar != nil && resp.Request != nil {
+		result.Cookies = client.Jar.Cookies(resp.Request.URL)
+	}
+
+	err = r.handleResponse(ctx, result)
+	if err != nil {
+		return result, err
+	}
+	err = r.checkLatency(result.Timings.Total)
+	if err != nil {
+		return result, err
+	}
+	return result, r.checkCertificates(result.TLS)
+}
+
+// A slow response is a failure if `max_latency` is set
+func (r *HttpRequest) checkLatency(total time.Duration) error {
+	maxLatency, err := parseDurationOrDefault(r.MaxLatency, 0)
+	if err != nil {
+		return err
+	}
+	if maxLatency > 0 && total > maxLatency {
+		return fmt.Errorf("response took %s, which is more than max_latency %s", total, maxLatency)
+	}
+	return nil
 }
 
-func (r *HttpRequest) handleResponse(resp *http.Response) error {
+func (r *HttpRequest) handleResponse(ctx context.Context, result *RequestResult) error {
+	resp := result.Response
 	if resp == nil {
 		return errors.New("got nil response object")
 	}
 	if !containsInt(resp.StatusCode, r.ExpectedResponseCodes) {
 		return fmt.Errorf("not an expected error code: %d is not in %x", resp.StatusCode, r.ExpectedResponseCodes)
 	}
+	for i := range r.Assertions {
+		err := r.Assertions[i].EvaluateResult(ctx, result)
+		if err != nil {
+			return err
+		}
+	}
 	// Nothing to parse
 	if len(r.VariablesFromResponse) == 0 {
 		return nil
 	}
 
 	for _, variable := range r.VariablesFromResponse {
-		err := variable.ParseFromResponse(resp)
+		err := variable.ParseFromResult(ctx, result)
 		if err != nil {
 			return err
 		}
@@ -146,66 +232,340 @@ func (r *HttpRequest) handleResponse(resp *http.Response) error {
 	return nil
 }
 
-func (h *HttpMonitor) Execute() {
-	client := httpclient.GetClient()
+// State for a single run of a monitor
+type execution struct {
+	monitor    *HttpMonitor
+	ctx        context.Context
+	logger     logr.Logger
+	httpClient *http.Client
+	fetcher    *resourceFetcher
+	tokens     *auth.TokenCache
+
+	// Variables available to the next request, except for the built-ins
+	variables VariableList
+	builtins  VariableList
+	result    *ExecutionResult
+	// Set once the cleanup requests run
+	cleanup bool
+}
+
+// The variables available to the next request. Built-ins come last, so variables from the monitor with the
+// same name take precedence, like they did before the built-in existed.
+func (e *execution) available() VariableList {
+	variables := make(VariableList, 0, len(e.variables)+len(e.builtins))
+	variables = append(variables, e.variables...)
+	return append(variables, e.builtins...)
+}
 
-	// These variables are available for all requests to use
-	availableVariables := VariableList{
-		&Variable{
-			Name:  "random-8",
-			From:  FromTypeProvided,
-			Value: rand.String(8),
-		},
-		&Variable{
-			Name:  "random-16",
-			From:  FromTypeProvided,
-			Value: rand.String(16),
+// Send a request unless its `when` expression is false. `ran` is false if the request was skipped.
+func (e *execution) runRequest(httpRequest HttpRequest) (status RequestStatus, ran bool, err error) {
+	run, err := e.shouldRun(&httpRequest)
+	if err != nil {
+		err = e.variables.redactError(err)
+		return newRequestStatus(httpRequest.Name, time.Now(), nil, err), true, err
+	}
+	if !run {
+		return newSkippedStatus(httpRequest.Name, "when is false"), false, nil
+	}
+	reason, err := e.checkDependencies(&httpRequest)
+	if err != nil {
+		err = e.variables.redactError(err)
+		return newRequestStatus(httpRequest.Name, time.Now(), nil, err), true, err
+	}
+	if reason != "" {
+		return newSkippedStatus(httpRequest.Name, reason), false, nil
+	}
+
+	e.logger.V(2).Info("executing request", "name", httpRequest.Name)
+	switch {
+	case httpRequest.ForEach != nil:
+		status, err = e.sendForEach(httpRequest)
+	case httpRequest.Paginate != nil:
+		status, err = e.sendPaginated(httpRequest)
+	default:
+		status, _, err = e.sendRequest(httpRequest, e.available())
+	}
+	return status, true, err
+}
+
+// Send a single request, retrying according to its retry policy, and record its metrics
+func (e *execution) sendRequest(httpRequest HttpRequest, variables VariableList) (RequestStatus, *RequestResult, error) {
+	httpRequest.AvailableVariables = variables
+	httpRequest.TemplateEngine = e.monitor.templateEngineFor(&httpRequest)
+	httpRequest.FailOnUnresolved = e.monitor.onUnresolvedFor(&httpRequest, e.cleanup) == UnresolvedPolicyError
+	retry := e.monitor.retryFor(&httpRequest)
+
+	start := time.Now()
+	var result *RequestResult
+	attempts := 0
+	authHeaders, err := e.monitor.authFor(&httpRequest).headers(e.ctx, e.fetcher, e.httpClient, e.tokens)
+	if err == nil {
+		httpRequest.AuthHeaders = authHeaders
+		for {
+			attempts++
+			httpRequest.VariablesFromResponse.clearValues()
+			result, err = httpRequest.sendRequest(e.ctx, e.httpClient)
+			if !e.cancelled() {
+				HandleAttemptMetrics(e.monitor, httpRequest, attempts, err)
+			}
+
+			if attempts >= retry.maxAttempts() || e.ctx.Err() != nil || !retry.retryable(result, err) {
+				break
+			}
+			delay, delayErr := retry.backoff(attempts)
+			if delayErr != nil {
+				err = delayErr
+				break
+			}
+			e.logger.V(1).Info("retrying request",
+				"request", httpRequest.Name,
+				"attempt", attempts,
+				"delay", delay.String(),
+				"error", e.variables.redact(err.Error()))
+			if !e.sleep(delay) {
+				err = e.ctx.Err()
+				break
+			}
+		}
+	}
+	// A stopped runner is not a failure of the monitored service
+	if !e.cancelled() {
+		HandleMetrics(e.monitor, httpRequest, result, err)
+	}
+
+	err = e.variables.redactError(err)
+	status := newRequestStatus(httpRequest.Name, start, result, err)
+	status.Attempts = attempts
+	return status, result, err
+}
+
+// Wait for the given duration. Returns false if the run was cancelled first.
+func (e *execution) sleep(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-e.ctx.Done():
+		return false
+	}
+}
+
+// State kept between runs of a monitor. Owned by the runner.
+// +kubebuilder:object:generate=false
+type RunnerState struct {
+	// The number of runs started
+	Sequence int64
+	// Kept between runs if `cookie_jar.persist_across_runs` is set
+	CookieJar http.CookieJar
+	// OAuth2 tokens, kept between runs until they expire
+	Tokens *auth.TokenCache
+	// The cached http client of the monitor. Released once the runner stops.
+	Client httpclient.Ref
+}
+
+// The cookie jar for a run, or nil if the cookie jar is disabled
+func (h *HttpMonitor) cookieJar(state *RunnerState) (http.CookieJar, error) {
+	if h.Spec.CookieJar == nil {
+		return nil, nil
+	}
+	if h.Spec.CookieJar.PersistAcrossRuns && state.CookieJar != nil {
+		return state.CookieJar, nil
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	if h.Spec.CookieJar.PersistAcrossRuns {
+		state.CookieJar = jar
+	}
+	return jar, nil
+}
+
+// The deadline for the requests of a run, or 0 for none
+func (h *HttpMonitor) runTimeout() (time.Duration, error) {
+	var interval time.Duration
+	now := time.Now()
+	if next, err := h.scheduledAfter(now); err == nil {
+		interval = next.Sub(now)
+	}
+	timeout, err := parseDurationOrDefault(h.Spec.RunTimeout, interval)
+	if err != nil {
+		return 0, fmt.Errorf("run_timeout: %w", err)
+	}
+	if timeout < 0 {
+		return 0, fmt.Errorf("run_timeout must be positive, got: %s", timeout)
+	}
+	return timeout, nil
+}
+
+// Whether the run was cancelled by the runner. Exceeding `run_timeout` does not count.
+func (e *execution) cancelled() bool {
+	return errors.Is(e.ctx.Err(), context.Canceled)
+}
+
+// Keeps the values of a context, but not its cancellation
+type detachedContext struct {
+	parent context.Context
+}
+
+func (c detachedContext) Deadline() (time.Time, bool)       { return time.Time{}, false }
+func (c detachedContext) Done() <-chan struct{}             { return nil }
+func (c detachedContext) Err() error                        { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+// Run all requests, followed by all cleanup requests.
+// Once ctx is cancelled, the remaining requests are skipped. Cleanup requests still run.
+// The reader is used to resolve Secrets and ConfigMaps referenced by the monitor.
+// The state is updated for the next run.
+func (h *HttpMonitor) Execute(ctx context.Context, reader client.Reader, state *RunnerState) *ExecutionResult {
+	state.Sequence++
+	if state.Tokens == nil {
+		state.Tokens = auth.NewTokenCache()
+	}
+
+	runTimeout, err := h.runTimeout()
+	if err != nil {
+		return &ExecutionResult{StartTime: time.Now(), Error: err.Error()}
+	}
+	var runCtx context.Context
+	var cancel context.CancelFunc
+	if runTimeout > 0 {
+		runCtx, cancel = context.WithTimeout(ctx, runTimeout)
+	} else {
+		runCtx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	e := &execution{
+		monitor: h,
+		ctx:     runCtx,
+		fetcher: newResourceFetcher(reader, h.Namespace),
+		tokens:  state.Tokens,
+		result: &ExecutionResult{
+			StartTime: time.Now(),
 		},
+		logger: httpMonitorUtilsLogger.
+			WithName("httpmonitor").
+			WithName("runner").
+			WithValues("namespace", h.Namespace, "name", h.Name),
+	}
+
+	httpClient, err := h.httpClient(e.ctx, e.fetcher, &state.Client)
+	if err != nil {
+		e.logger.Error(err, "failed to configure http client")
+		e.result.Error = err.Error()
+		return e.result
+	}
+	e.httpClient = httpClient
+
+	jar, err := h.cookieJar(state)
+	if err != nil {
+		e.logger.Error(err, "failed to create cookie jar")
+		e.result.Error = err.Error()
+		return e.result
 	}
-	for key, val := range h.Spec.Environment {
-		availableVariables = append(availableVariables, &Variable{
-			Name:  key,
-			From:  FromTypeProvided,
-			Value: val,
-		})
+	if jar != nil {
+		// The client is shared with other monitors, so the jar is set on a copy
+		withJar := *httpClient
+		withJar.Jar = jar
+		e.httpClient = &withJar
 	}
 
-	logger := httpMonitorUtilsLogger.
-		WithName("httpmonitor").
-		WithName("runner").
-		WithValues("namespace", h.Namespace, "name", h.Name)
+	environment, err := h.resolveEnvironment(e.ctx, e.fetcher)
+	if err != nil {
+		e.logger.Error(err, "failed to resolve environment")
+		e.result.Error = err.Error()
+		return e.result
+	}
+
+	// These variables are available for all requests to use
+	e.builtins = builtinVariables(newRunInfo(h, state.Sequence, e.result.StartTime))
+	e.variables = environment
 
-	logger.Info("executing requests")
+	e.logger.Info("executing requests")
 
 	// run requests
-	for _, httpRequest := range h.Spec.Requests {
-		entry := logger.WithValues("name", httpRequest.Name)
-		entry.V(2).Info("executing request")
-		httpRequest.VariablesFromResponse.clearValues()
-		httpRequest.AvailableVariables = availableVariables
-
-		resp, err := httpRequest.sendRequest(client)
-		HandleMetrics(h, httpRequest, resp)
-		if err != nil {
-			entry.Error(err, "failed to complete request", "name", httpRequest.Name)
+	runCleanup := true
+requests:
+	for _, step := range requestSteps(h.Spec.Requests) {
+		if err := e.ctx.Err(); err != nil {
+			e.logger.Info("run cancelled, skipping the remaining requests", "reason", err.Error())
+			if errors.Is(err, context.DeadlineExceeded) {
+				e.result.Error = fmt.Sprintf("run exceeded run_timeout of %s", runTimeout)
+			}
 			break
 		}
-		if len(httpRequest.VariablesFromResponse) > 0 {
-			availableVariables = append(availableVariables, httpRequest.VariablesFromResponse...)
+
+		// Results are handled in order, so variables and failures do not depend on timing
+		var failed *HttpRequest
+		for _, result := range e.runStep(step) {
+			httpRequest := result.request
+			entry := e.logger.WithValues("name", httpRequest.Name)
+
+			e.result.Requests = append(e.result.Requests, result.status)
+			if !result.ran {
+				entry.V(2).Info("skipped request", "reason", result.status.Skipped)
+				continue
+			}
+			if result.err != nil {
+				entry.Error(result.err, "failed to complete request", "name", httpRequest.Name)
+				if failed == nil {
+					failed = &httpRequest
+				}
+				continue
+			}
+			if len(httpRequest.VariablesFromResponse) > 0 {
+				e.variables = append(e.variables, httpRequest.VariablesFromResponse...)
+			}
+		}
+
+		if failed != nil {
+			switch failed.onFailure() {
+			case OnFailureContinue:
+				continue
+			case OnFailureStop:
+				runCleanup = false
+			}
+			break requests
 		}
 	}
 
-	// run cleanup
+	if !runCleanup {
+		e.logger.Info("skipping cleanup after a failed request")
+		return e.result
+	}
+
+	// run cleanup, even if the run was cancelled. Cleanup has its own deadline, so shutdown does not wait forever.
+	var cleanupCancel context.CancelFunc
+	if conf.GlobalConfig.CleanupTimeout > 0 {
+		e.ctx, cleanupCancel = context.WithTimeout(detachedContext{ctx}, conf.GlobalConfig.CleanupTimeout)
+	} else {
+		e.ctx, cleanupCancel = context.WithCancel(detachedContext{ctx})
+	}
+	defer cleanupCancel()
+	e.cleanup = true
 	for _, httpRequest := range h.Spec.Cleanup {
-		entry := logger.WithValues("name", httpRequest.Name)
-		entry.V(2).Info("executing cleanup request")
-		httpRequest.VariablesFromResponse.clearValues()
-		httpRequest.AvailableVariables = availableVariables
+		entry := e.logger.WithValues("name", httpRequest.Name)
+
+		// Not sending a cleanup request may leave resources behind, so it counts as a failure
+		if e.ctx.Err() != nil {
+			err := fmt.Errorf("cleanup exceeded --cleanup-timeout of %s", conf.GlobalConfig.CleanupTimeout)
+			e.result.Cleanup = append(e.result.Cleanup, newRequestStatus(httpRequest.Name, time.Now(), nil, err))
+			entry.Error(err, "skipped cleanup request")
+			continue
+		}
 
-		resp, err := httpRequest.sendRequest(client)
-		HandleMetrics(h, httpRequest, resp)
+		status, ran, err := e.runRequest(httpRequest)
+		e.result.Cleanup = append(e.result.Cleanup, status)
+		if !ran {
+			entry.V(2).Info("skipped cleanup request", "reason", status.Skipped)
+			continue
+		}
 		if err != nil {
 			entry.Error(err, "failed to complete cleanup request", "name", httpRequest.Name)
 		}
 	}
+
+	return e.result
 }
@@ -25,9 +25,18 @@ The R&D leading to these results received funding from the:
 package v1alpha1
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/oregondesignservices/monitoring-controller/internal/conf"
+	"github.com/oregondesignservices/monitoring-controller/internal/httpclient"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestVariableList_newReplacer(t *testing.T) {
@@ -82,7 +91,7 @@ func TestHttpRequest_BuildRequest(t *testing.T) {
 		AvailableVariables: availableVariables,
 	}
 
-	req, err := r.BuildRequest()
+	req, err := r.BuildRequest(context.Background())
 	if err != nil {
 		t.Errorf("got err while building request: %s", err)
 		return
@@ -93,3 +102,186 @@ func TestHttpRequest_BuildRequest(t *testing.T) {
 		t.Errorf("unexpected url. Got: %s, wanted: %s", req.URL.String(), expectedUrl)
 	}
 }
+
+func TestHttpRequest_sendRequestTimings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	r := &HttpRequest{
+		Method:                "GET",
+		Url:                   server.URL,
+		ExpectedResponseCodes: []int{200},
+	}
+
+	result, err := r.sendRequest(context.Background(), server.Client())
+	if err != nil {
+		t.Fatalf("got err while sending request: %s", err)
+	}
+	if result.Timings.Total < 20*time.Millisecond {
+		t.Errorf("total duration too short: %s", result.Timings.Total)
+	}
+	if result.Timings.FirstByte <= 0 || result.Timings.FirstByte > result.Timings.Total {
+		t.Errorf("unexpected time to first byte: %s", result.Timings.FirstByte)
+	}
+
+	r.MaxLatency = "1ms"
+	_, err = r.sendRequest(context.Background(), server.Client())
+	if err == nil {
+		t.Errorf("expected max_latency to fail the request")
+	}
+}
+
+func TestTimingTracer_parallelDials(t *testing.T) {
+	var timings RequestTimings
+	tracer := newTimingTracer(&timings)
+	trace := tracer.clientTrace()
+	tracer.begin()
+
+	// IPv4 and IPv6 dialed in parallel. Only the IPv6 dial succeeds.
+	trace.ConnectStart("tcp", "[::1]:80")
+	trace.ConnectStart("tcp", "127.0.0.1:80")
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		trace.ConnectDone("tcp", "127.0.0.1:80", errors.New("connection refused"))
+	}()
+	go func() {
+		defer wg.Done()
+		time.Sleep(10 * time.Millisecond)
+		trace.ConnectDone("tcp", "[::1]:80", nil)
+	}()
+	wg.Wait()
+	tracer.done()
+
+	connect := timings.Connect
+	if connect < 10*time.Millisecond {
+		t.Errorf("expected the successful dial to be recorded, got %s", connect)
+	}
+
+	// A late dial after the request is done does not change the timings
+	trace.ConnectStart("tcp", "10.0.0.1:80")
+	trace.ConnectDone("tcp", "10.0.0.1:80", nil)
+	trace.GotFirstResponseByte()
+	if timings.Connect != connect || timings.FirstByte != 0 {
+		t.Errorf("timings changed after done: %+v", timings)
+	}
+}
+
+func TestHttpMonitor_ExecuteCleanupTimeout(t *testing.T) {
+	httpclient.Initialize(5 * time.Second)
+	conf.GlobalConfig.CleanupTimeout = 200 * time.Millisecond
+	defer func() { conf.GlobalConfig.CleanupTimeout = 0 }()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	monitor := &HttpMonitor{Spec: HttpMonitorSpec{
+		Requests: []HttpRequest{{Name: "create", Method: "GET", Url: server.URL, ExpectedResponseCodes: []int{503}}},
+		Cleanup: []HttpRequest{
+			{
+				Name:                  "delete",
+				Method:                "GET",
+				Url:                   server.URL,
+				ExpectedResponseCodes: []int{200},
+				Retry:                 &RetryPolicy{MaxAttempts: 10, Delay: "1s", RetryOnStatusCodes: []int{503}},
+			},
+			{Name: "delete more", Method: "GET", Url: server.URL, ExpectedResponseCodes: []int{200}},
+		},
+	}}
+
+	// Like after the shutdown grace period, the run is already cancelled when cleanup starts
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	result := monitor.Execute(ctx, nil, &RunnerState{})
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected cleanup retries to stop at the cleanup timeout, took: %s", elapsed)
+	}
+	if len(result.Cleanup) != 2 || result.Cleanup[0].Error == "" {
+		t.Fatalf("expected the retried cleanup request to fail, got: %+v", result.Cleanup)
+	}
+	if !strings.Contains(result.Cleanup[1].Error, "cleanup-timeout") {
+		t.Errorf("expected the remaining cleanup request to fail with the timeout, got: %+v", result.Cleanup[1])
+	}
+}
+
+func TestHttpMonitor_ExecuteCancellation(t *testing.T) {
+	httpclient.Initialize(5 * time.Second)
+
+	var mutex sync.Mutex
+	var visited []string
+	started := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		visited = append(visited, r.URL.Path)
+		mutex.Unlock()
+		if r.URL.Path == "/slow" {
+			started <- struct{}{}
+			select {
+			case <-time.After(2 * time.Second):
+			case <-r.Context().Done():
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	newRequest := func(path string) HttpRequest {
+		return HttpRequest{Name: path, Method: "GET", Url: server.URL + path, ExpectedResponseCodes: []int{200}}
+	}
+
+	tests := []struct {
+		TestName   string
+		RunTimeout string
+		Cancel     bool
+	}{
+		{"run-timeout", "100ms", false},
+		{"cancelled", "", true},
+	}
+
+	for _, testdata := range tests {
+		visited = nil
+		monitor := &HttpMonitor{Spec: HttpMonitorSpec{
+			RunTimeout: testdata.RunTimeout,
+			Requests:   []HttpRequest{newRequest("/slow"), newRequest("/next")},
+			Cleanup:    []HttpRequest{newRequest("/cleanup")},
+		}}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		if testdata.Cancel {
+			go func() {
+				<-started
+				cancel()
+			}()
+		}
+		start := time.Now()
+		result := monitor.Execute(ctx, nil, &RunnerState{})
+		cancel()
+		// Drain the signal for the next test
+		select {
+		case <-started:
+		default:
+		}
+
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("[%s] expected the slow request to be cancelled, took: %s", testdata.TestName, elapsed)
+		}
+		expected := []string{"/slow", "/cleanup"}
+		if fmt.Sprint(visited) != fmt.Sprint(expected) {
+			t.Errorf("[%s] unexpected requests. Got: %v, expected: %v", testdata.TestName, visited, expected)
+		}
+		if !result.Failed() {
+			t.Errorf("[%s] expected the cancelled request to fail", testdata.TestName)
+		}
+		if len(result.Cleanup) != 1 || result.CleanupFailed() {
+			t.Errorf("[%s] expected cleanup to succeed, got: %+v", testdata.TestName, result.Cleanup)
+		}
+	}
+}
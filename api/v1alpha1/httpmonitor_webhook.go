@@ -0,0 +1,264 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"time"
+)
+
+var httpmonitorlog = logf.Log.WithName("httpmonitor-resource")
+
+func (h *HttpMonitor) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(h).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create,path=/mutate-monitoring-raisingthefloor-org-v1alpha1-httpmonitor,mutating=true,failurePolicy=fail,groups=monitoring.raisingthefloor.org,resources=httpmonitors,versions=v1alpha1,name=mhttpmonitor.kb.io
+
+var _ webhook.Defaulter = &HttpMonitor{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type.
+// The webhook only runs on create, so existing monitors keep their behavior.
+func (h *HttpMonitor) Default() {
+	httpmonitorlog.V(1).Info("default", "namespace", h.Namespace, "name", h.Name)
+	if h.Spec.Strict == nil {
+		strict := true
+		h.Spec.Strict = &strict
+	}
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-monitoring-raisingthefloor-org-v1alpha1-httpmonitor,mutating=false,failurePolicy=fail,groups=monitoring.raisingthefloor.org,resources=httpmonitors,versions=v1alpha1,name=vhttpmonitor.kb.io
+
+var _ webhook.Validator = &HttpMonitor{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (h *HttpMonitor) ValidateCreate() error {
+	httpmonitorlog.V(1).Info("validate create", "namespace", h.Namespace, "name", h.Name)
+	return h.validate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (h *HttpMonitor) ValidateUpdate(old runtime.Object) error {
+	httpmonitorlog.V(1).Info("validate update", "namespace", h.Namespace, "name", h.Name)
+	return h.validate()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (h *HttpMonitor) ValidateDelete() error {
+	return nil
+}
+
+func (h *HttpMonitor) validate() error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	for name := range h.Spec.Environment {
+		if isBuiltinVariable(name) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("environment").Key(name), name, "shadows a built-in variable"))
+		}
+	}
+	for i, variable := range h.Spec.EnvironmentRefs {
+		if isBuiltinVariable(variable.Name) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("environment_refs").Index(i).Child("name"), variable.Name, "shadows a built-in variable"))
+		}
+	}
+
+	allErrs = append(allErrs, h.validateSchedule(specPath)...)
+	if _, err := h.initialJitter(); err != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("initial_jitter"), h.Spec.InitialJitter, err.Error()))
+	}
+	if _, err := h.runTimeout(); err != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("run_timeout"), h.Spec.RunTimeout, err.Error()))
+	}
+
+	for i := range h.Spec.Requests {
+		allErrs = append(allErrs, h.validateRequest(&h.Spec.Requests[i], specPath.Child("requests").Index(i))...)
+	}
+	allErrs = append(allErrs, validateParallelGroups(h.Spec.Requests, specPath.Child("requests"))...)
+	for i := range h.Spec.Cleanup {
+		cleanupPath := specPath.Child("cleanup").Index(i)
+		allErrs = append(allErrs, h.validateRequest(&h.Spec.Cleanup[i], cleanupPath)...)
+		if h.Spec.Cleanup[i].ParallelGroup != "" {
+			allErrs = append(allErrs, field.Forbidden(cleanupPath.Child("parallel_group"), "cleanup requests run sequentially"))
+		}
+	}
+	allErrs = append(allErrs, h.validateDependsOn(specPath)...)
+	allErrs = append(allErrs, h.validateDefinedVariables(specPath)...)
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("HttpMonitor").GroupKind(), h.Name, allErrs)
+}
+
+func (h *HttpMonitor) validateSchedule(specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if h.Spec.Period != nil && h.Spec.Schedule != "" {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("schedule"), "period and schedule cannot be set together"))
+	} else if h.Spec.Period == nil && h.Spec.Schedule == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("period"), "either period or schedule is required"))
+	}
+	if h.Spec.Period != nil && h.Spec.Period.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("period"), h.Spec.Period.Duration.String(), "must be positive"))
+	}
+	if _, err := h.location(); err != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("time_zone"), h.Spec.TimeZone, err.Error()))
+	} else if h.Spec.Schedule != "" {
+		if _, err := h.scheduledAfter(time.Now()); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("schedule"), h.Spec.Schedule, err.Error()))
+		}
+	}
+
+	allErrs = append(allErrs, validateWindows(h.Spec.ActiveWindows, specPath.Child("active_windows"))...)
+	allErrs = append(allErrs, validateWindows(h.Spec.BlackoutWindows, specPath.Child("blackout_windows"))...)
+	return allErrs
+}
+
+func validateWindows(windows []TimeWindow, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for i := range windows {
+		allErrs = append(allErrs, windows[i].validate(path.Index(i))...)
+	}
+	return allErrs
+}
+
+func (h *HttpMonitor) validateRequest(r *HttpRequest, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	for i, variable := range r.VariablesFromResponse {
+		variablePath := path.Child("vars_from_response").Index(i)
+		if isBuiltinVariable(variable.Name) {
+			allErrs = append(allErrs, field.Invalid(variablePath.Child("name"), variable.Name, "shadows a built-in variable"))
+		}
+		if err := validateExpression(variable.ExpressionType, variable.JsonPath); err != nil {
+			allErrs = append(allErrs, field.Invalid(variablePath.Child("json_path"), variable.JsonPath, err.Error()))
+		}
+		allErrs = append(allErrs, variable.validateSource(variablePath)...)
+	}
+	for i, assertion := range r.Assertions {
+		if err := validateExpression(assertion.ExpressionType, assertion.JsonPath); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("assertions").Index(i).Child("json_path"), assertion.JsonPath, err.Error()))
+		}
+	}
+
+	if r.ForEach != nil && r.Paginate != nil {
+		allErrs = append(allErrs, field.Forbidden(path.Child("paginate"), "cannot be combined with for_each"))
+	}
+	if r.Paginate != nil {
+		paginate := &Variable{From: r.Paginate.From, Regex: r.Paginate.Regex, XPath: r.Paginate.XPath}
+		allErrs = append(allErrs, paginate.validateSource(path.Child("paginate"))...)
+		if err := validateExpression(r.Paginate.ExpressionType, r.Paginate.JsonPath); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("paginate", "json_path"), r.Paginate.JsonPath, err.Error()))
+		}
+	}
+
+	if r.When != "" {
+		if err := validateWhen(r.When); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("when"), r.When, err.Error()))
+		}
+	}
+
+	if h.templateEngineFor(r) == TemplateEngineGo {
+		r.eachTemplateField(path, func(text string, path *field.Path) {
+			if err := validateTemplate(text); err != nil {
+				allErrs = append(allErrs, field.Invalid(path, text, err.Error()))
+			}
+		})
+	}
+
+	return allErrs
+}
+
+// Requests of a parallel group must be consecutive and extract distinct variables
+func validateParallelGroups(requests []HttpRequest, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	seenGroups := make(map[string]bool)
+	var groupVariables map[string]bool
+	for i, request := range requests {
+		group := request.ParallelGroup
+		if group == "" {
+			continue
+		}
+		groupPath := path.Index(i).Child("parallel_group")
+		if i == 0 || requests[i-1].ParallelGroup != group {
+			if seenGroups[group] {
+				allErrs = append(allErrs, field.Invalid(groupPath, group, "requests of a parallel group must be consecutive"))
+			}
+			seenGroups[group] = true
+			groupVariables = make(map[string]bool)
+		}
+		for j, variable := range request.VariablesFromResponse {
+			if groupVariables[variable.Name] {
+				allErrs = append(allErrs, field.Duplicate(path.Index(i).Child("vars_from_response").Index(j).Child("name"), variable.Name))
+			}
+			groupVariables[variable.Name] = true
+		}
+	}
+
+	return allErrs
+}
+
+// Requests can only depend on requests that run before them. Requests of a parallel group run at the same time.
+func (h *HttpMonitor) validateDependsOn(specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	earlier := make(map[string]bool)
+	check := func(r *HttpRequest, path *field.Path) {
+		if r.DependsOn == nil {
+			return
+		}
+		for i, name := range r.DependsOn.Requests {
+			if !earlier[name] {
+				allErrs = append(allErrs, field.Invalid(path.Child("depends_on", "requests").Index(i), name, "not a request that runs earlier"))
+			}
+		}
+	}
+
+	i := 0
+	for _, step := range requestSteps(h.Spec.Requests) {
+		for j := range step {
+			check(&step[j], specPath.Child("requests").Index(i+j))
+		}
+		for _, request := range step {
+			earlier[request.Name] = true
+		}
+		i += len(step)
+	}
+	for i := range h.Spec.Cleanup {
+		check(&h.Spec.Cleanup[i], specPath.Child("cleanup").Index(i))
+		earlier[h.Spec.Cleanup[i].Name] = true
+	}
+
+	return allErrs
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHttpMonitor_validate(t *testing.T) {
+	tests := []struct {
+		TestName       string
+		Spec           HttpMonitorSpec
+		ExpectedErrors []string
+	}{
+		{
+			"valid",
+			HttpMonitorSpec{
+				TemplateEngine: TemplateEngineGo,
+				Requests: []HttpRequest{
+					{Url: "http://test.com/{{ .id | urlquery }}", Body: `{"id": {{ toJson .id }}}`},
+				},
+			},
+			nil,
+		},
+		{
+			"simple-engine-is-not-parsed",
+			HttpMonitorSpec{
+				Requests: []HttpRequest{
+					{Url: "http://test.com/{{ .id "},
+				},
+			},
+			nil,
+		},
+		{
+			"invalid-templates",
+			HttpMonitorSpec{
+				Requests: []HttpRequest{
+					{
+						TemplateEngine: TemplateEngineGo,
+						Url:            "http://test.com/{{ .id ",
+						Headers:        http.Header{"Authorization": []string{"{{ unknown }}"}},
+					},
+				},
+				Cleanup: []HttpRequest{
+					{TemplateEngine: TemplateEngineGo, Body: "{{ end }}"},
+				},
+			},
+			[]string{"spec.requests[0].url", "spec.requests[0].headers[Authorization]", "spec.cleanup[0].body"},
+		},
+		{
+			"shadowed-builtins",
+			HttpMonitorSpec{
+				Environment: map[string]string{"uuid": "fixed"},
+				Requests: []HttpRequest{
+					{VariablesFromResponse: VariableList{&Variable{Name: "run-sequence"}}},
+				},
+			},
+			[]string{"spec.environment[uuid]", "spec.requests[0].vars_from_response[0].name"},
+		},
+		{
+			"parallel-groups",
+			HttpMonitorSpec{
+				Requests: []HttpRequest{
+					{ParallelGroup: "a", VariablesFromResponse: VariableList{&Variable{Name: "id"}}},
+					{ParallelGroup: "a", VariablesFromResponse: VariableList{&Variable{Name: "id"}}},
+					{},
+					{ParallelGroup: "a"},
+				},
+				Cleanup: []HttpRequest{
+					{ParallelGroup: "b"},
+				},
+			},
+			[]string{"spec.requests[1].vars_from_response[0].name", "spec.requests[3].parallel_group", "spec.cleanup[0].parallel_group"},
+		},
+		{
+			"depends-on",
+			HttpMonitorSpec{
+				Requests: []HttpRequest{
+					{Name: "create"},
+					{Name: "check a", ParallelGroup: "checks", DependsOn: &DependsOn{Requests: []string{"create"}}},
+					{Name: "check b", ParallelGroup: "checks", DependsOn: &DependsOn{Requests: []string{"check a"}}},
+					{Name: "update", DependsOn: &DependsOn{Requests: []string{"check b", "delete"}}},
+				},
+				Cleanup: []HttpRequest{
+					{Name: "delete", DependsOn: &DependsOn{Requests: []string{"create", "missing"}}},
+				},
+			},
+			[]string{"spec.requests[2].depends_on.requests[0]", "spec.requests[3].depends_on.requests[1]", "spec.cleanup[0].depends_on.requests[1]"},
+		},
+	}
+
+	for _, testdata := range tests {
+		monitor := &HttpMonitor{Spec: testdata.Spec}
+		monitor.Name = "test"
+		if monitor.Spec.Period == nil && monitor.Spec.Schedule == "" {
+			// Scheduling is covered by TestHttpMonitor_validateSchedule
+			monitor.Spec.Period = &metav1.Duration{Duration: time.Minute}
+		}
+		err := monitor.ValidateCreate()
+		if len(testdata.ExpectedErrors) == 0 {
+			if err != nil {
+				t.Errorf("[%s] got unexpected err: %s", testdata.TestName, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("[%s] expected error but got none", testdata.TestName)
+			continue
+		}
+		for _, expected := range testdata.ExpectedErrors {
+			if !strings.Contains(err.Error(), expected) {
+				t.Errorf("[%s] expected error for %s, got: %s", testdata.TestName, expected, err)
+			}
+		}
+	}
+}
+
+func TestHttpMonitor_validateSchedule(t *testing.T) {
+	minute := &metav1.Duration{Duration: time.Minute}
+	tests := []struct {
+		TestName       string
+		Spec           HttpMonitorSpec
+		ExpectedErrors []string
+	}{
+		{"period", HttpMonitorSpec{Period: minute}, nil},
+		{"schedule", HttpMonitorSpec{Schedule: "*/5 * * * *", TimeZone: "America/Los_Angeles"}, nil},
+		{"descriptor", HttpMonitorSpec{Schedule: "@hourly"}, nil},
+		{"neither", HttpMonitorSpec{}, []string{"spec.period"}},
+		{"both", HttpMonitorSpec{Period: minute, Schedule: "@hourly"}, []string{"spec.schedule"}},
+		{"negative-period", HttpMonitorSpec{Period: &metav1.Duration{Duration: -time.Minute}}, []string{"spec.period"}},
+		{"invalid-schedule", HttpMonitorSpec{Schedule: "every minute"}, []string{"spec.schedule"}},
+		{"schedule-never-runs", HttpMonitorSpec{Schedule: "0 0 30 2 *"}, []string{"spec.schedule"}},
+		{"schedule-time-zone", HttpMonitorSpec{Schedule: "CRON_TZ=Europe/Paris 0 * * * *"}, []string{"spec.schedule"}},
+		{"invalid-time-zone", HttpMonitorSpec{Schedule: "@hourly", TimeZone: "Mars/Olympus_Mons"}, []string{"spec.time_zone"}},
+		{
+			"invalid-windows",
+			HttpMonitorSpec{
+				Period:          minute,
+				ActiveWindows:   []TimeWindow{{Start: "08:00", End: "18:00", Days: []Weekday{"mon", "someday"}}},
+				BlackoutWindows: []TimeWindow{{Start: "2:00", End: "24:00"}},
+			},
+			[]string{"spec.active_windows[0].days[1]", "spec.blackout_windows[0].start", "spec.blackout_windows[0].end"},
+		},
+	}
+
+	for _, testdata := range tests {
+		monitor := &HttpMonitor{Spec: testdata.Spec}
+		errs := monitor.validateSchedule(field.NewPath("spec"))
+		if len(testdata.ExpectedErrors) == 0 {
+			if len(errs) != 0 {
+				t.Errorf("[%s] got unexpected errs: %s", testdata.TestName, errs.ToAggregate())
+			}
+			continue
+		}
+		if len(errs) != len(testdata.ExpectedErrors) {
+			t.Errorf("[%s] expected %d errors, got: %v", testdata.TestName, len(testdata.ExpectedErrors), errs.ToAggregate())
+			continue
+		}
+		for i, expected := range testdata.ExpectedErrors {
+			if errs[i].Field != expected {
+				t.Errorf("[%s] expected error for %s, got: %s", testdata.TestName, expected, errs[i])
+			}
+		}
+	}
+}
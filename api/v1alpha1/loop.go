@@ -0,0 +1,197 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultForEachLimit = 100
+	defaultMaxPages     = 10
+)
+
+// Combine the statuses of all iterations of a request. The first failure, or the last iteration, is used.
+func aggregateStatus(name string, start time.Time, statuses []RequestStatus) RequestStatus {
+	if len(statuses) == 0 {
+		return newSkippedStatus(name, "no items")
+	}
+	status := statuses[len(statuses)-1]
+	if failed := firstFailure(statuses); failed != nil {
+		status = *failed
+	}
+	startTime := metav1.NewTime(start)
+	status.Name = name
+	status.LastExecution = &startTime
+	status.Duration = time.Since(start).String()
+	status.Iterations = len(statuses)
+	return status
+}
+
+// The variables for a single item of a for_each
+func forEachVariables(as string, index int, item interface{}) (VariableList, error) {
+	value, err := formatExpressionValue(item)
+	if err != nil {
+		return nil, err
+	}
+	variables := VariableList{
+		&Variable{Name: as, From: FromTypeProvided, Value: value},
+		&Variable{Name: as + "_index", From: FromTypeProvided, Value: strconv.Itoa(index)},
+	}
+	if fields, ok := item.(map[string]interface{}); ok {
+		keys := make([]string, 0, len(fields))
+		for key := range fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fieldValue, err := formatExpressionValue(fields[key])
+			if err != nil {
+				return nil, err
+			}
+			variables = append(variables, &Variable{Name: as + "." + key, From: FromTypeProvided, Value: fieldValue})
+		}
+	}
+	return variables, nil
+}
+
+func (f *ForEach) as() string {
+	if f.As == "" {
+		return "item"
+	}
+	return f.As
+}
+
+func (f *ForEach) limit() int {
+	if f.Limit <= 0 {
+		return defaultForEachLimit
+	}
+	return f.Limit
+}
+
+// Send the request for every item. All items are sent, even if some fail.
+func (e *execution) sendForEach(httpRequest HttpRequest) (RequestStatus, error) {
+	start := time.Now()
+	forEach := httpRequest.ForEach
+
+	var items []interface{}
+	source := e.available().values()[forEach.Variable]
+	if err := json.Unmarshal([]byte(source), &items); err != nil {
+		err = fmt.Errorf("for_each variable %s is not a JSON array: %w", forEach.Variable, err)
+		return newRequestStatus(httpRequest.Name, start, nil, err), err
+	}
+	if len(items) > forEach.limit() {
+		e.logger.V(1).Info("ignoring for_each items beyond the limit",
+			"request", httpRequest.Name, "items", len(items), "limit", forEach.limit())
+		items = items[:forEach.limit()]
+	}
+
+	var statuses []RequestStatus
+	var firstErr error
+	for i, item := range items {
+		itemVariables, err := forEachVariables(forEach.as(), i, item)
+		if err != nil {
+			return newRequestStatus(httpRequest.Name, start, nil, err), err
+		}
+		status, _, err := e.sendRequest(httpRequest, append(itemVariables, e.available()...))
+		statuses = append(statuses, status)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return aggregateStatus(httpRequest.Name, start, statuses), firstErr
+}
+
+func (p *Paginate) maxPages() int {
+	if p.MaxPages <= 0 {
+		return defaultMaxPages
+	}
+	return p.MaxPages
+}
+
+// The url of the page after the result, or "" if it is the last page
+func (p *Paginate) nextUrl(ctx context.Context, result *RequestResult) (string, error) {
+	v := &Variable{
+		From:           p.From,
+		JsonPath:       p.JsonPath,
+		ExpressionType: p.ExpressionType,
+		Regex:          p.Regex,
+		XPath:          p.XPath,
+	}
+	if err := v.ParseFromResult(ctx, result); err != nil || v.Value == "" || v.Value == "null" {
+		return "", nil
+	}
+
+	next, err := url.Parse(v.Value)
+	if err != nil {
+		return "", fmt.Errorf("next page url: %w", err)
+	}
+	if result.Response.Request != nil && result.Response.Request.URL != nil {
+		next = result.Response.Request.URL.ResolveReference(next)
+	}
+	return next.String(), nil
+}
+
+// Send the request, then follow the next page links until there are none or max_pages is reached.
+// Stops at the first failed page.
+func (e *execution) sendPaginated(httpRequest HttpRequest) (RequestStatus, error) {
+	start := time.Now()
+	paginate := httpRequest.Paginate
+
+	var statuses []RequestStatus
+	for page := 1; ; page++ {
+		status, result, err := e.sendRequest(httpRequest, e.available())
+		statuses = append(statuses, status)
+		if err != nil {
+			return aggregateStatus(httpRequest.Name, start, statuses), err
+		}
+
+		next, err := paginate.nextUrl(e.ctx, result)
+		if err != nil {
+			statuses[len(statuses)-1] = newRequestStatus(httpRequest.Name, start, result, err)
+			return aggregateStatus(httpRequest.Name, start, statuses), err
+		}
+		if next == "" {
+			break
+		}
+		if page >= paginate.maxPages() {
+			e.logger.V(1).Info("stopping pagination at max_pages", "request", httpRequest.Name, "maxPages", paginate.maxPages())
+			break
+		}
+
+		// The next url is used as is, without templating or query params
+		httpRequest.Url = next
+		httpRequest.QueryParams = nil
+		httpRequest.TemplateEngine = TemplateEngineSimple
+	}
+	return aggregateStatus(httpRequest.Name, start, statuses), nil
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"github.com/oregondesignservices/monitoring-controller/internal/httpclient"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHttpMonitor_ExecuteForEach(t *testing.T) {
+	httpclient.Initialize(time.Second)
+
+	var mutex sync.Mutex
+	var visited []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/items" {
+			_, _ = w.Write([]byte(`{"items": [{"id": 1, "name": "a"}, {"id": 2, "name": "b"}, {"id": 3, "name": "broken"}]}`))
+			return
+		}
+		mutex.Lock()
+		visited = append(visited, r.URL.Path+"?"+r.URL.RawQuery)
+		mutex.Unlock()
+		if strings.Contains(r.URL.Path, "broken") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	monitor := &HttpMonitor{Spec: HttpMonitorSpec{
+		Requests: []HttpRequest{
+			{
+				Name:                  "list",
+				Method:                "GET",
+				Url:                   server.URL + "/items",
+				ExpectedResponseCodes: []int{200},
+				VariablesFromResponse: VariableList{
+					&Variable{Name: "items", From: FromTypeBodyJson, ExpressionType: ExpressionTypeJsonPath, JsonPath: "{.items}"},
+				},
+			},
+			{
+				Name:                  "get item",
+				Method:                "GET",
+				Url:                   server.URL + "/items/{entry.name}?id={entry.id}&index={entry_index}",
+				ExpectedResponseCodes: []int{200},
+				ForEach:               &ForEach{Variable: "items", As: "entry"},
+			},
+		},
+	}}
+
+	result := monitor.Execute(context.Background(), nil, &RunnerState{})
+	expected := []string{"/items/a?id=1&index=0", "/items/b?id=2&index=1", "/items/broken?id=3&index=2"}
+	if fmt.Sprint(visited) != fmt.Sprint(expected) {
+		t.Errorf("unexpected requests. Got: %v, expected: %v", visited, expected)
+	}
+	if len(result.Requests) != 2 {
+		t.Fatalf("expected one status per request, got: %d", len(result.Requests))
+	}
+	status := result.Requests[1]
+	if status.Iterations != 3 {
+		t.Errorf("unexpected iterations: %d", status.Iterations)
+	}
+	if status.StatusCode != http.StatusInternalServerError || status.Error == "" {
+		t.Errorf("expected the failed item to be reported, got: %+v", status)
+	}
+
+	// A limit ignores the remaining items
+	visited = nil
+	monitor.Spec.Requests[1].ForEach.Limit = 2
+	result = monitor.Execute(context.Background(), nil, &RunnerState{})
+	if len(visited) != 2 || result.Failed() {
+		t.Errorf("expected 2 successful requests, got: %v", visited)
+	}
+}
+
+func TestHttpMonitor_ExecutePaginate(t *testing.T) {
+	httpclient.Initialize(time.Second)
+
+	pages := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pages++
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 3 {
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"next": "/list?page=%d"}`, page+1)))
+			return
+		}
+		_, _ = w.Write([]byte(`{"next": null}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		TestName      string
+		MaxPages      int
+		ExpectedPages int
+	}{
+		{"all-pages", 0, 4},
+		{"max-pages", 2, 2},
+	}
+
+	for _, testdata := range tests {
+		pages = 0
+		monitor := &HttpMonitor{Spec: HttpMonitorSpec{
+			Requests: []HttpRequest{
+				{
+					Name:                  "list",
+					Method:                "GET",
+					Url:                   server.URL + "/list",
+					QueryParams:           map[string][]string{"page": {"0"}},
+					ExpectedResponseCodes: []int{200},
+					Paginate:              &Paginate{From: FromTypeBodyJson, JsonPath: "/next", MaxPages: testdata.MaxPages},
+				},
+			},
+		}}
+
+		result := monitor.Execute(context.Background(), nil, &RunnerState{})
+		if result.Failed() {
+			t.Errorf("[%s] unexpected failure: %s", testdata.TestName, result.FirstFailure().Error)
+		}
+		if pages != testdata.ExpectedPages {
+			t.Errorf("[%s] unexpected pages. Got: %d, expected: %d", testdata.TestName, pages, testdata.ExpectedPages)
+		}
+		if result.Requests[0].Iterations != testdata.ExpectedPages {
+			t.Errorf("[%s] unexpected iterations: %d", testdata.TestName, result.Requests[0].Iterations)
+		}
+	}
+}
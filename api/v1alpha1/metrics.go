@@ -1,24 +1,145 @@
 package v1alpha1
 
 import (
+	"errors"
 	"fmt"
 	"github.com/oregondesignservices/monitoring-controller/internal/metrics"
-	"net/http"
 	"strconv"
+	"sync"
+	"time"
 )
 
-func HandleMetrics(m *HttpMonitor, req HttpRequest, resp *http.Response) {
+func HandleMetrics(m *HttpMonitor, req HttpRequest, result *RequestResult, err error) {
 	status := 599
-	if resp != nil {
-		status = resp.StatusCode
+	if result != nil && result.Response != nil {
+		status = result.Response.StatusCode
 	}
 	stringStatus := strconv.Itoa(status)
+	crd := fmt.Sprintf("%s/%s", m.Namespace, m.Name)
 
 	metrics.HttpResponseCounter.WithLabelValues(req.Url, stringStatus).Inc()
 
 	metrics.CrdHttpResponseCounter.WithLabelValues(
 		"HttpMonitor/v1alpha1",
-		fmt.Sprintf("%s/%s", m.Namespace, m.Name),
+		crd,
 		req.Name,
 		stringStatus).Inc()
+
+	if result != nil {
+		metrics.CrdHttpRequestDuration.WithLabelValues(
+			"HttpMonitor/v1alpha1",
+			crd,
+			req.Name,
+			stringStatus).Observe(result.Timings.Total.Seconds())
+
+		for phase, duration := range result.Timings.Phases() {
+			// Skip phases that did not happen, like DNS on a reused connection
+			if duration <= 0 {
+				continue
+			}
+			metrics.CrdHttpRequestPhaseDuration.WithLabelValues(
+				"HttpMonitor/v1alpha1",
+				crd,
+				req.Name,
+				stringStatus,
+				phase).Observe(duration.Seconds())
+		}
+	}
+
+	// Without a TLS connection, like after a failed handshake, the last known expiry is kept
+	if result != nil && result.TLS != nil {
+		recordCertExpiry(crd, req.Name, result.TLS)
+	}
+
+	if err != nil {
+		metrics.CrdHttpRequestFailureCounter.WithLabelValues(
+			"HttpMonitor/v1alpha1",
+			crd,
+			req.Name).Inc()
+	}
+
+	var assertionErr *AssertionError
+	if errors.As(err, &assertionErr) {
+		metrics.CrdHttpAssertionFailureCounter.WithLabelValues(
+			"HttpMonitor/v1alpha1",
+			crd,
+			req.Name,
+			assertionErr.Assertion).Inc()
+	}
+}
+
+// Count every attempt of a request, so retried failures stay visible without failing the request
+func HandleAttemptMetrics(m *HttpMonitor, req HttpRequest, attempt int, err error) {
+	attemptLabel := "first"
+	if attempt > 1 {
+		attemptLabel = "retry"
+	}
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	metrics.CrdHttpAttemptCounter.WithLabelValues(
+		"HttpMonitor/v1alpha1",
+		fmt.Sprintf("%s/%s", m.Namespace, m.Name),
+		req.Name,
+		attemptLabel,
+		result).Inc()
+}
+
+// Count every run of a monitor, including skipped runs, so they don't look like missing data
+func HandleRunMetrics(m *HttpMonitor, result RunResult) {
+	metrics.CrdRunCounter.WithLabelValues(
+		"HttpMonitor/v1alpha1",
+		fmt.Sprintf("%s/%s", m.Namespace, m.Name),
+		string(result)).Inc()
+}
+
+// Remove the series of a monitor that are only valid while it runs
+func RemoveMetrics(namespace, name string) {
+	crd := fmt.Sprintf("%s/%s", namespace, name)
+	certPositionsLock.Lock()
+	defer certPositionsLock.Unlock()
+	for requestName, positions := range certPositions[crd] {
+		for _, position := range positions {
+			metrics.TLSCertExpiryGauge.DeleteLabelValues("HttpMonitor/v1alpha1", crd, requestName, position)
+		}
+	}
+	delete(certPositions, crd)
+}
+
+// The certificate positions each request of each monitor last presented, by crd, then request name.
+// Used to delete only the series of certificates that are no longer presented, so the others never go missing.
+var (
+	certPositions     = make(map[string]map[string][]string)
+	certPositionsLock sync.Mutex
+)
+
+func recordCertExpiry(crd, requestName string, info *TLSInfo) {
+	certPositionsLock.Lock()
+	defer certPositionsLock.Unlock()
+
+	var positions []string
+	for i, cert := range info.Certificates {
+		position := "leaf"
+		if i > 0 {
+			position = "chain_" + strconv.Itoa(i)
+		}
+		positions = append(positions, position)
+		metrics.TLSCertExpiryGauge.WithLabelValues(
+			"HttpMonitor/v1alpha1",
+			crd,
+			requestName,
+			position).Set(time.Until(cert.NotAfter).Seconds())
+	}
+
+	// Ex: the server stopped sending an intermediate certificate
+	for i, previous := range certPositions[crd][requestName] {
+		if i >= len(positions) {
+			metrics.TLSCertExpiryGauge.DeleteLabelValues("HttpMonitor/v1alpha1", crd, requestName, previous)
+		}
+	}
+	if certPositions[crd] == nil {
+		certPositions[crd] = make(map[string][]string)
+	}
+	certPositions[crd][requestName] = positions
 }
@@ -0,0 +1,89 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"sync"
+)
+
+const defaultMaxConcurrency = 4
+
+// The outcome of a request that is part of a step
+type stepResult struct {
+	request HttpRequest
+	status  RequestStatus
+	ran     bool
+	err     error
+}
+
+// Split requests into steps. Consecutive requests of the same parallel group form a single step,
+// every other request is a step of its own.
+func requestSteps(requests []HttpRequest) [][]HttpRequest {
+	var steps [][]HttpRequest
+	for i, request := range requests {
+		last := len(steps) - 1
+		if request.ParallelGroup != "" && last >= 0 && requests[i-1].ParallelGroup == request.ParallelGroup {
+			steps[last] = append(steps[last], request)
+			continue
+		}
+		steps = append(steps, []HttpRequest{request})
+	}
+	return steps
+}
+
+func (h *HttpMonitor) maxConcurrency() int {
+	if h.Spec.MaxConcurrency <= 0 {
+		return defaultMaxConcurrency
+	}
+	return h.Spec.MaxConcurrency
+}
+
+// Run all requests of a step, in parallel if there is more than one.
+// Results are in the same order as the requests.
+func (e *execution) runStep(step []HttpRequest) []stepResult {
+	results := make([]stepResult, len(step))
+	if len(step) == 1 {
+		status, ran, err := e.runRequest(step[0])
+		results[0] = stepResult{step[0], status, ran, err}
+		return results
+	}
+
+	e.logger.V(2).Info("executing parallel group", "group", step[0].ParallelGroup, "requests", len(step))
+	limit := make(chan struct{}, e.monitor.maxConcurrency())
+	var wg sync.WaitGroup
+	for i := range step {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			status, ran, err := e.runRequest(step[i])
+			results[i] = stepResult{step[i], status, ran, err}
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
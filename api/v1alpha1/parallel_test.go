@@ -0,0 +1,177 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"github.com/oregondesignservices/monitoring-controller/internal/httpclient"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRequestSteps(t *testing.T) {
+	tests := []struct {
+		TestName      string
+		Groups        []string
+		ExpectedSizes []int
+	}{
+		{"sequential", []string{"", "", ""}, []int{1, 1, 1}},
+		{"one-group", []string{"", "a", "a", "a", ""}, []int{1, 3, 1}},
+		{"adjacent-groups", []string{"a", "a", "b", "b"}, []int{2, 2}},
+		{"empty", nil, nil},
+	}
+
+	for _, testdata := range tests {
+		var requests []HttpRequest
+		for _, group := range testdata.Groups {
+			requests = append(requests, HttpRequest{ParallelGroup: group})
+		}
+		var sizes []int
+		for _, step := range requestSteps(requests) {
+			sizes = append(sizes, len(step))
+		}
+		if fmt.Sprint(sizes) != fmt.Sprint(testdata.ExpectedSizes) {
+			t.Errorf("[%s] unexpected steps. Got: %v, expected: %v", testdata.TestName, sizes, testdata.ExpectedSizes)
+		}
+	}
+}
+
+func TestHttpMonitor_ExecuteParallelGroup(t *testing.T) {
+	httpclient.Initialize(time.Second)
+
+	var mutex sync.Mutex
+	active, maxActive := 0, 0
+	finalBody := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/final" {
+			body, _ := ioutil.ReadAll(r.Body)
+			finalBody = string(body)
+			return
+		}
+		mutex.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		mutex.Unlock()
+
+		time.Sleep(50 * time.Millisecond)
+
+		mutex.Lock()
+		active--
+		mutex.Unlock()
+
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(fmt.Sprintf(`{"path": "%s"}`, r.URL.Path)))
+	}))
+	defer server.Close()
+
+	newRequest := func(name string, path string) HttpRequest {
+		return HttpRequest{
+			Name:                  name,
+			Method:                "GET",
+			Url:                   server.URL + path,
+			ExpectedResponseCodes: []int{200},
+			ParallelGroup:         "checks",
+			VariablesFromResponse: VariableList{
+				&Variable{Name: name, From: FromTypeBodyJson, JsonPath: "/path"},
+			},
+		}
+	}
+
+	tests := []struct {
+		TestName          string
+		MaxConcurrency    int
+		Paths             []string
+		ExpectedMaxActive int
+		ExpectedFailure   string
+		ExpectedFinal     string
+	}{
+		{"all-at-once", 0, []string{"/a", "/b", "/c"}, 3, "", "/a /b /c"},
+		{"limited", 2, []string{"/a", "/b", "/c", "/d"}, 2, "", "/a /b /c /d"},
+		{"first-failure-stops", 0, []string{"/a", "/broken", "/c"}, 3, "check1", ""},
+	}
+
+	for _, testdata := range tests {
+		var requests []HttpRequest
+		var names []string
+		for i, path := range testdata.Paths {
+			name := fmt.Sprintf("check%d", i)
+			names = append(names, "{"+name+"}")
+			requests = append(requests, newRequest(name, path))
+		}
+		requests = append(requests, HttpRequest{
+			Name:                  "final",
+			Method:                "GET",
+			Url:                   server.URL + "/final",
+			ExpectedResponseCodes: []int{200},
+			Body:                  fmt.Sprint(names),
+		})
+
+		monitor := &HttpMonitor{Spec: HttpMonitorSpec{
+			MaxConcurrency: testdata.MaxConcurrency,
+			Requests:       requests,
+		}}
+
+		maxActive, finalBody = 0, ""
+		result := monitor.Execute(context.Background(), nil, &RunnerState{})
+
+		if maxActive != testdata.ExpectedMaxActive {
+			t.Errorf("[%s] unexpected concurrency. Got: %d, expected: %d", testdata.TestName, maxActive, testdata.ExpectedMaxActive)
+		}
+		// Statuses are in the order of the spec, regardless of completion order
+		for i := range testdata.Paths {
+			if result.Requests[i].Name != fmt.Sprintf("check%d", i) {
+				t.Errorf("[%s] unexpected status order: %s", testdata.TestName, result.Requests[i].Name)
+			}
+		}
+
+		if testdata.ExpectedFailure != "" {
+			if first := result.FirstFailure(); first == nil || first.Name != testdata.ExpectedFailure {
+				t.Errorf("[%s] expected %s to fail, got: %+v", testdata.TestName, testdata.ExpectedFailure, first)
+			}
+			if len(result.Requests) != len(testdata.Paths) {
+				t.Errorf("[%s] expected the requests after the group to not run", testdata.TestName)
+			}
+			continue
+		}
+		if result.Failed() {
+			t.Errorf("[%s] unexpected failure: %s", testdata.TestName, result.FirstFailure().Error)
+			continue
+		}
+		// Variables from the group are available after it completes
+		if finalBody != "["+testdata.ExpectedFinal+"]" {
+			t.Errorf("[%s] unexpected final body. Got: %s, expected: [%s]", testdata.TestName, finalBody, testdata.ExpectedFinal)
+		}
+	}
+}
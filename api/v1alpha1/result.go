@@ -0,0 +1,113 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// The outcome of sending a single request
+// +kubebuilder:object:generate=false
+type RequestResult struct {
+	Response *http.Response
+	Timings  RequestTimings
+	// Set for HTTPS requests
+	TLS *TLSInfo
+	// Cookies in the monitor's cookie jar for the response url, if the jar is enabled
+	Cookies []*http.Cookie
+}
+
+// Details of a certificate presented by the server
+// +kubebuilder:object:generate=false
+type CertificateInfo struct {
+	Subject  string
+	Issuer   string
+	DNSNames []string
+	NotAfter time.Time
+}
+
+// Details of the TLS connection of a request
+// +kubebuilder:object:generate=false
+type TLSInfo struct {
+	Version            uint16
+	CipherSuite        uint16
+	ServerName         string
+	NegotiatedProtocol string
+	// The leaf certificate first, followed by the rest of the chain
+	Certificates []CertificateInfo
+}
+
+func newTLSInfo(state *tls.ConnectionState) *TLSInfo {
+	info := &TLSInfo{
+		Version:            state.Version,
+		CipherSuite:        state.CipherSuite,
+		ServerName:         state.ServerName,
+		NegotiatedProtocol: state.NegotiatedProtocol,
+	}
+	for _, cert := range state.PeerCertificates {
+		info.Certificates = append(info.Certificates, CertificateInfo{
+			Subject:  cert.Subject.String(),
+			Issuer:   cert.Issuer.String(),
+			DNSNames: cert.DNSNames,
+			NotAfter: cert.NotAfter,
+		})
+	}
+	return info
+}
+
+// The TLS details shown in the status
+func (t *TLSInfo) status() *TLSStatus {
+	status := &TLSStatus{
+		Version:            fmt.Sprintf("0x%04x", t.Version),
+		CipherSuite:        fmt.Sprintf("0x%04x", t.CipherSuite),
+		ServerName:         t.ServerName,
+		NegotiatedProtocol: t.NegotiatedProtocol,
+	}
+	for name, version := range tlsVersions {
+		if version == t.Version {
+			status.Version = name
+		}
+	}
+	if len(t.Certificates) > 0 {
+		status.Subject = t.Certificates[0].Subject
+		status.Issuer = t.Certificates[0].Issuer
+		status.DNSNames = t.Certificates[0].DNSNames
+	}
+	return status
+}
+
+// The certificate that expires first, or nil if there are none
+func (t *TLSInfo) EarliestExpiry() *CertificateInfo {
+	var earliest *CertificateInfo
+	for i := range t.Certificates {
+		if earliest == nil || t.Certificates[i].NotAfter.Before(earliest.NotAfter) {
+			earliest = &t.Certificates[i]
+		}
+	}
+	return earliest
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"context"
+	"errors"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"net"
+	"time"
+)
+
+// The request failed before a response was received
+// +kubebuilder:object:generate=false
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// The retry policy used by a request. A request's own policy overrides the monitor's.
+func (h *HttpMonitor) retryFor(r *HttpRequest) *RetryPolicy {
+	if r.Retry != nil {
+		return r.Retry
+	}
+	return h.Spec.Retry
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+func (p *RetryPolicy) retriesOn(condition RetryCondition) bool {
+	if len(p.RetryOn) == 0 {
+		return condition == RetryConditionConnectionError || condition == RetryConditionTimeout
+	}
+	for _, c := range p.RetryOn {
+		if c == condition {
+			return true
+		}
+	}
+	return false
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// Whether a failed attempt should be retried
+func (p *RetryPolicy) retryable(result *RequestResult, err error) bool {
+	if p == nil || err == nil {
+		return false
+	}
+	var transportErr *TransportError
+	if errors.As(err, &transportErr) {
+		if isTimeout(err) {
+			return p.retriesOn(RetryConditionTimeout)
+		}
+		return p.retriesOn(RetryConditionConnectionError)
+	}
+	if result != nil && result.Response != nil {
+		return containsInt(result.Response.StatusCode, p.RetryOnStatusCodes)
+	}
+	return false
+}
+
+// How long to wait after the given attempt, starting at 1
+func (p *RetryPolicy) backoff(attempt int) (time.Duration, error) {
+	delay, err := parseDurationOrDefault(p.Delay, time.Second)
+	if err != nil {
+		return 0, err
+	}
+	if p.Backoff == BackoffTypeExponential {
+		maxDelay, err := parseDurationOrDefault(p.MaxDelay, 30*time.Second)
+		if err != nil {
+			return 0, err
+		}
+		for i := 1; i < attempt && delay < maxDelay; i++ {
+			delay *= 2
+		}
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+	if p.Jitter && delay > 1 {
+		delay = time.Duration(rand.Int63nRange(int64(delay/2), int64(delay)))
+	}
+	return delay, nil
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"context"
+	"github.com/oregondesignservices/monitoring-controller/internal/httpclient"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	tests := []struct {
+		TestName      string
+		Policy        *RetryPolicy
+		Attempt       int
+		ExpectedDelay time.Duration
+	}{
+		{
+			"fixed-default",
+			&RetryPolicy{},
+			3,
+			time.Second,
+		},
+		{
+			"fixed",
+			&RetryPolicy{Delay: "2s"},
+			2,
+			2 * time.Second,
+		},
+		{
+			"exponential",
+			&RetryPolicy{Backoff: BackoffTypeExponential, Delay: "1s"},
+			3,
+			4 * time.Second,
+		},
+		{
+			"exponential-capped",
+			&RetryPolicy{Backoff: BackoffTypeExponential, Delay: "1s", MaxDelay: "5s"},
+			10,
+			5 * time.Second,
+		},
+	}
+
+	for _, testdata := range tests {
+		delay, err := testdata.Policy.backoff(testdata.Attempt)
+		if err != nil {
+			t.Errorf("[%s] got unexpected err: %s", testdata.TestName, err)
+			continue
+		}
+		if delay != testdata.ExpectedDelay {
+			t.Errorf("[%s] unexpected delay. Got: %s, expected: %s", testdata.TestName, delay, testdata.ExpectedDelay)
+		}
+	}
+
+	jittered := &RetryPolicy{Delay: "1s", Jitter: true}
+	for i := 0; i < 20; i++ {
+		delay, _ := jittered.backoff(1)
+		if delay < 500*time.Millisecond || delay > time.Second {
+			t.Errorf("jittered delay out of range: %s", delay)
+		}
+	}
+}
+
+func TestExecution_sendRequestRetries(t *testing.T) {
+	httpclient.Initialize(time.Second)
+
+	failures := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		TestName         string
+		Failures         int
+		Retry            *RetryPolicy
+		ExpectErr        bool
+		ExpectedAttempts int
+	}{
+		{
+			"no-retry",
+			1,
+			nil,
+			true,
+			1,
+		},
+		{
+			"recovers",
+			2,
+			&RetryPolicy{MaxAttempts: 3, Delay: "1ms", RetryOnStatusCodes: []int{503}},
+			false,
+			3,
+		},
+		{
+			"gives-up",
+			5,
+			&RetryPolicy{MaxAttempts: 3, Delay: "1ms", RetryOnStatusCodes: []int{503}},
+			true,
+			3,
+		},
+		{
+			"status-not-retryable",
+			2,
+			&RetryPolicy{MaxAttempts: 3, Delay: "1ms"},
+			true,
+			1,
+		},
+	}
+
+	for _, testdata := range tests {
+		failures = testdata.Failures
+		e := &execution{
+			monitor:    &HttpMonitor{Spec: HttpMonitorSpec{Retry: testdata.Retry}},
+			ctx:        context.Background(),
+			logger:     httpMonitorUtilsLogger,
+			httpClient: httpclient.GetClient(),
+		}
+		status, _, err := e.sendRequest(HttpRequest{
+			Name:                  "test",
+			Method:                "GET",
+			Url:                   server.URL,
+			ExpectedResponseCodes: []int{200},
+		}, nil)
+		if err == nil && testdata.ExpectErr {
+			t.Errorf("[%s] expected error but got none", testdata.TestName)
+		}
+		if err != nil && !testdata.ExpectErr {
+			t.Errorf("[%s] got unexpected err: %s", testdata.TestName, err)
+		}
+		if status.Attempts != testdata.ExpectedAttempts {
+			t.Errorf("[%s] unexpected attempts. Got: %d, expected: %d", testdata.TestName, status.Attempts, testdata.ExpectedAttempts)
+		}
+	}
+}
+
+func TestRetryPolicy_retryableConnectionError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	r := &HttpRequest{Method: "GET", Url: url, ExpectedResponseCodes: []int{200}}
+	result, err := r.sendRequest(context.Background(), http.DefaultClient)
+	if err == nil {
+		t.Fatalf("expected a connection error")
+	}
+
+	if !(&RetryPolicy{}).retryable(result, err) {
+		t.Errorf("connection errors should be retried by default")
+	}
+	if (&RetryPolicy{RetryOn: []RetryCondition{RetryConditionTimeout}}).retryable(result, err) {
+		t.Errorf("connection errors should not be retried when only timeouts are")
+	}
+}
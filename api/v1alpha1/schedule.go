@@ -0,0 +1,138 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"errors"
+	"fmt"
+	"github.com/oregondesignservices/monitoring-controller/internal/conf"
+	"github.com/robfig/cron/v3"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"strings"
+	"time"
+)
+
+func (h *HttpMonitor) runImmediately() bool {
+	if h.Spec.RunImmediately != nil {
+		return *h.Spec.RunImmediately
+	}
+	return conf.GlobalConfig.RunImmediately
+}
+
+func (h *HttpMonitor) initialJitter() (time.Duration, error) {
+	jitter, err := parseDurationOrDefault(h.Spec.InitialJitter, conf.GlobalConfig.InitialJitter)
+	if err != nil {
+		return 0, fmt.Errorf("initial_jitter: %w", err)
+	}
+	if jitter < 0 {
+		return 0, fmt.Errorf("initial_jitter must be positive, got: %s", jitter)
+	}
+	return jitter, nil
+}
+
+// The time zone of the schedule and the windows
+func (h *HttpMonitor) location() (*time.Location, error) {
+	if h.Spec.TimeZone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(h.Spec.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("time_zone: %w", err)
+	}
+	return loc, nil
+}
+
+func (h *HttpMonitor) cronSchedule() (cron.Schedule, error) {
+	// The time zone comes from time_zone, so the windows use the same one
+	if strings.HasPrefix(h.Spec.Schedule, "TZ=") || strings.HasPrefix(h.Spec.Schedule, "CRON_TZ=") {
+		return nil, errors.New("schedule: use time_zone to set the time zone")
+	}
+	schedule, err := cron.ParseStandard(h.Spec.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+// The first run after t, ignoring runs that would be late
+func (h *HttpMonitor) scheduledAfter(t time.Time) (time.Time, error) {
+	if h.Spec.Schedule == "" {
+		if h.Spec.Period == nil || h.Spec.Period.Duration <= 0 {
+			return t, errors.New("either a positive period or a schedule is required")
+		}
+		return t.Add(h.Spec.Period.Duration), nil
+	}
+
+	schedule, err := h.cronSchedule()
+	if err != nil {
+		return t, err
+	}
+	loc, err := h.location()
+	if err != nil {
+		return t, err
+	}
+	next := schedule.Next(t.In(loc))
+	if next.IsZero() {
+		return t, fmt.Errorf("schedule '%s' never runs", h.Spec.Schedule)
+	}
+	return next, nil
+}
+
+// How long to wait before the first run: until the first scheduled run, or nothing with `run_immediately`,
+// plus a random jitter. If only the jitter is invalid, the delay without it is returned with the error.
+func (h *HttpMonitor) InitialDelay(now time.Time) (time.Duration, error) {
+	var delay time.Duration
+	if !h.runImmediately() {
+		next, err := h.scheduledAfter(now)
+		if err != nil {
+			return 0, err
+		}
+		delay = next.Sub(now)
+	}
+
+	jitter, err := h.initialJitter()
+	if err != nil {
+		return delay, err
+	}
+	if jitter > 0 {
+		delay += time.Duration(rand.Int63nRange(0, int64(jitter)))
+	}
+	return delay, nil
+}
+
+// When to run next, after a run that was scheduled for `last`. Like a ticker, runs that would
+// already be late are dropped.
+func (h *HttpMonitor) NextRun(last time.Time, now time.Time) (time.Time, error) {
+	next, err := h.scheduledAfter(last)
+	if err != nil || !next.Before(now) {
+		return next, err
+	}
+	if h.Spec.Schedule != "" {
+		return h.scheduledAfter(now)
+	}
+	period := h.Spec.Period.Duration
+	missed := now.Sub(next)/period + 1
+	return next.Add(missed * period), nil
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"github.com/oregondesignservices/monitoring-controller/internal/conf"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+func TestHttpMonitor_InitialDelay(t *testing.T) {
+	defer func() {
+		conf.GlobalConfig.RunImmediately = false
+		conf.GlobalConfig.InitialJitter = 0
+	}()
+
+	yes, no := true, false
+	tests := []struct {
+		TestName       string
+		GlobalRun      bool
+		GlobalJitter   time.Duration
+		RunImmediately *bool
+		InitialJitter  string
+		ExpectedMin    time.Duration
+		ExpectedMax    time.Duration
+	}{
+		{"default", false, 0, nil, "", time.Hour, time.Hour},
+		{"run-immediately", false, 0, &yes, "", 0, 0},
+		{"global-run-immediately", true, 0, nil, "", 0, 0},
+		{"monitor-overrides-global", true, 0, &no, "", time.Hour, time.Hour},
+		{"jitter", false, 0, &yes, "10s", 0, 10 * time.Second},
+		{"global-jitter", false, 10 * time.Second, nil, "", time.Hour, time.Hour + 10*time.Second},
+		{"monitor-jitter-overrides-global", false, time.Minute, &yes, "1s", 0, time.Second},
+	}
+
+	for _, testdata := range tests {
+		conf.GlobalConfig.RunImmediately = testdata.GlobalRun
+		conf.GlobalConfig.InitialJitter = testdata.GlobalJitter
+		monitor := &HttpMonitor{Spec: HttpMonitorSpec{
+			Period:         &metav1.Duration{Duration: time.Hour},
+			RunImmediately: testdata.RunImmediately,
+			InitialJitter:  testdata.InitialJitter,
+		}}
+
+		// The jitter is random, so check a few delays
+		for i := 0; i < 20; i++ {
+			delay, err := monitor.InitialDelay(time.Now())
+			if err != nil {
+				t.Errorf("[%s] got unexpected err: %s", testdata.TestName, err)
+				break
+			}
+			if delay < testdata.ExpectedMin || delay > testdata.ExpectedMax {
+				t.Errorf("[%s] delay %s is not in [%s, %s]", testdata.TestName, delay, testdata.ExpectedMin, testdata.ExpectedMax)
+				break
+			}
+		}
+	}
+
+	conf.GlobalConfig.RunImmediately = false
+	conf.GlobalConfig.InitialJitter = 0
+	now := time.Date(2020, 5, 1, 12, 10, 30, 0, time.UTC)
+	monitor := &HttpMonitor{Spec: HttpMonitorSpec{Schedule: "*/15 * * * *"}}
+	if delay, err := monitor.InitialDelay(now); err != nil || delay != 4*time.Minute+30*time.Second {
+		t.Errorf("unexpected delay until the first scheduled run: %s, %v", delay, err)
+	}
+
+	monitor = &HttpMonitor{Spec: HttpMonitorSpec{}}
+	if _, err := monitor.InitialDelay(now); err == nil {
+		t.Errorf("expected a monitor without a period or schedule to fail")
+	}
+
+	monitor = &HttpMonitor{Spec: HttpMonitorSpec{Period: &metav1.Duration{Duration: time.Hour}, InitialJitter: "-1s"}}
+	if _, err := monitor.InitialDelay(time.Now()); err == nil {
+		t.Errorf("expected a negative jitter to fail")
+	}
+}
+
+func TestHttpMonitor_NextRun(t *testing.T) {
+	everyMinute := HttpMonitorSpec{Period: &metav1.Duration{Duration: time.Minute}}
+	nightly := HttpMonitorSpec{Schedule: "30 2 * * *", TimeZone: "America/Los_Angeles"}
+	last := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	pacific, _ := time.LoadLocation("America/Los_Angeles")
+
+	tests := []struct {
+		TestName string
+		Spec     HttpMonitorSpec
+		Last     time.Time
+		Now      time.Time
+		Expected time.Time
+	}{
+		{"on-time", everyMinute, last, last.Add(10 * time.Second), last.Add(time.Minute)},
+		{"late-run-is-dropped", everyMinute, last, last.Add(90 * time.Second), last.Add(2 * time.Minute)},
+		{"several-late-runs", everyMinute, last, last.Add(5*time.Minute + time.Second), last.Add(6 * time.Minute)},
+		{"schedule", HttpMonitorSpec{Schedule: "*/15 * * * *"}, last, last.Add(time.Second), last.Add(15 * time.Minute)},
+		{"schedule-late-runs-are-dropped", HttpMonitorSpec{Schedule: "*/15 * * * *"}, last, last.Add(40 * time.Minute), last.Add(45 * time.Minute)},
+		{"schedule-time-zone", nightly, last, last, time.Date(2020, 5, 2, 2, 30, 0, 0, pacific)},
+		// 02:30 does not exist on the day daylight saving time starts
+		{"schedule-daylight-saving-time", nightly, time.Date(2020, 3, 7, 2, 30, 0, 0, pacific), time.Date(2020, 3, 7, 2, 31, 0, 0, pacific), time.Date(2020, 3, 9, 2, 30, 0, 0, pacific)},
+	}
+
+	for _, testdata := range tests {
+		monitor := &HttpMonitor{Spec: testdata.Spec}
+		next, err := monitor.NextRun(testdata.Last, testdata.Now)
+		if err != nil {
+			t.Errorf("[%s] got unexpected err: %s", testdata.TestName, err)
+			continue
+		}
+		if !next.Equal(testdata.Expected) {
+			t.Errorf("[%s] Got: %s, expected: %s", testdata.TestName, next, testdata.Expected)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+func (v *Variable) parseFromBodyRegex(resp *http.Response) error {
+	re, err := regexp.Compile(v.Regex)
+	if err != nil {
+		return err
+	}
+	group, err := v.regexGroup(re)
+	if err != nil {
+		return err
+	}
+
+	body := readBodyAndReset(resp)
+	match := re.FindSubmatch(body)
+	if match == nil {
+		return notFound("regex did not match the body: %s", v.Regex)
+	}
+	v.Value = string(match[group])
+	return nil
+}
+
+// The index of the capture group to use
+func (v *Variable) regexGroup(re *regexp.Regexp) (int, error) {
+	if v.Group == "" {
+		if re.NumSubexp() == 0 {
+			return 0, nil
+		}
+		return 1, nil
+	}
+	if index, err := strconv.Atoi(v.Group); err == nil {
+		if index < 0 || index > re.NumSubexp() {
+			return 0, fmt.Errorf("regex has no group %d: %s", index, v.Regex)
+		}
+		return index, nil
+	}
+	for index, name := range re.SubexpNames() {
+		if index > 0 && name == v.Group {
+			return index, nil
+		}
+	}
+	return 0, fmt.Errorf("regex has no group named %s: %s", v.Group, v.Regex)
+}
+
+// Evaluate an XPath expression. Node sets use the value of the first node.
+func evaluateXPath(expression string, navigator xpath.NodeNavigator) (string, error) {
+	expr, err := xpath.Compile(expression)
+	if err != nil {
+		return "", err
+	}
+
+	switch result := expr.Evaluate(navigator).(type) {
+	case *xpath.NodeIterator:
+		if !result.MoveNext() {
+			return "", notFound("no results for xpath: %s", expression)
+		}
+		return result.Current().Value(), nil
+	case float64:
+		return strconv.FormatFloat(result, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(result), nil
+	case string:
+		return result, nil
+	default:
+		return "", fmt.Errorf("unexpected result for xpath: %s", expression)
+	}
+}
+
+func (v *Variable) parseFromBodyXml(resp *http.Response) error {
+	if v.XPath == "" {
+		return errors.New("body_xml requires xpath")
+	}
+	doc, err := xmlquery.Parse(bytes.NewReader(readBodyAndReset(resp)))
+	if err != nil {
+		return err
+	}
+	v.Value, err = evaluateXPath(v.XPath, xmlquery.CreateXPathNavigator(doc))
+	return err
+}
+
+func (v *Variable) parseFromBodyHtml(resp *http.Response) error {
+	doc, err := htmlquery.Parse(bytes.NewReader(readBodyAndReset(resp)))
+	if err != nil {
+		return err
+	}
+
+	if v.XPath != "" {
+		v.Value, err = evaluateXPath(v.XPath, htmlquery.CreateXPathNavigator(doc))
+		return err
+	}
+	if v.Selector == "" {
+		return errors.New("body_html requires xpath or selector")
+	}
+
+	selector, err := cascadia.Compile(v.Selector)
+	if err != nil {
+		return err
+	}
+	node := selector.MatchFirst(doc)
+	if node == nil {
+		return notFound("no element matches selector: %s", v.Selector)
+	}
+	if v.Attribute == "" {
+		v.Value = htmlquery.InnerText(node)
+		return nil
+	}
+	for _, attr := range node.Attr {
+		if attr.Key == v.Attribute {
+			v.Value = attr.Val
+			return nil
+		}
+	}
+	return notFound("element matching %s has no attribute %s", v.Selector, v.Attribute)
+}
+
+// Cookies set by the response take precedence over cookies already in the jar
+func (v *Variable) parseFromCookie(resp *http.Response, jar []*http.Cookie) error {
+	pieces := v.jsonPathToPieces()
+	if len(pieces) != 1 {
+		return fmt.Errorf("cannot parse jsonpath for cookie variable: %s", v.JsonPath)
+	}
+	for _, cookies := range [][]*http.Cookie{resp.Cookies(), jar} {
+		for _, cookie := range cookies {
+			if cookie.Name == pieces[0] {
+				v.Value = cookie.Value
+				return nil
+			}
+		}
+	}
+	return notFound("no cookie named %s", pieces[0])
+}
+
+func (v *Variable) parseFromFinalUrl(resp *http.Response) error {
+	if resp.Request == nil || resp.Request.URL == nil {
+		return errors.New("response has no request url")
+	}
+	v.Value = resp.Request.URL.String()
+	return nil
+}
+
+// Check the settings used by the variable's source
+func (v *Variable) validateSource(path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	switch v.From {
+	case FromTypeBodyRegex:
+		re, err := regexp.Compile(v.Regex)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("regex"), v.Regex, err.Error()))
+		} else if _, err := v.regexGroup(re); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("group"), v.Group, err.Error()))
+		}
+	case FromTypeBodyXml, FromTypeBodyHtml:
+		if v.XPath != "" {
+			if _, err := xpath.Compile(v.XPath); err != nil {
+				allErrs = append(allErrs, field.Invalid(path.Child("xpath"), v.XPath, err.Error()))
+			}
+		} else if v.From == FromTypeBodyXml {
+			allErrs = append(allErrs, field.Required(path.Child("xpath"), "body_xml requires xpath"))
+		} else if v.Selector == "" {
+			allErrs = append(allErrs, field.Required(path.Child("selector"), "body_html requires xpath or selector"))
+		} else if _, err := cascadia.Compile(v.Selector); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("selector"), v.Selector, err.Error()))
+		}
+	}
+
+	return allErrs
+}
@@ -0,0 +1,249 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"context"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestVariable_ParseFromResultSources(t *testing.T) {
+	htmlBody := `<html><body>
+		<form action="/login">
+			<input type="hidden" name="csrf" value="token-123">
+			<h1 class="title">Sign <b>in</b></h1>
+		</form>
+	</body></html>`
+	xmlBody := `<?xml version="1.0"?>
+		<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+			<soap:Body>
+				<GetUserResponse><User id="42"><Name>bob</Name></User><User id="43"/></GetUserResponse>
+			</soap:Body>
+		</soap:Envelope>`
+	finalUrl, _ := url.Parse("https://example.com/final")
+
+	newResult := func(body string) *RequestResult {
+		header := http.Header{}
+		header.Add("Set-Cookie", "session=abc123; Path=/; HttpOnly")
+		header.Add("Set-Cookie", "theme=dark")
+		return &RequestResult{
+			Response: &http.Response{
+				StatusCode: 201,
+				Header:     header,
+				Body:       newReaderCloser(body),
+				Request:    &http.Request{URL: finalUrl},
+			},
+			Timings: RequestTimings{Total: 1500 * time.Millisecond},
+		}
+	}
+
+	tests := []struct {
+		TestName      string
+		Var           *Variable
+		Body          string
+		ExpectErr     bool
+		ExpectedValue string
+	}{
+		{
+			"regex-first-group",
+			&Variable{From: FromTypeBodyRegex, Regex: `name="csrf" value="([^"]+)"`},
+			htmlBody,
+			false,
+			"token-123",
+		},
+		{
+			"regex-named-group",
+			&Variable{From: FromTypeBodyRegex, Regex: `name="(?P<name>\w+)" value="(?P<value>[^"]+)"`, Group: "value"},
+			htmlBody,
+			false,
+			"token-123",
+		},
+		{
+			"regex-numbered-group",
+			&Variable{From: FromTypeBodyRegex, Regex: `name="(\w+)" value="([^"]+)"`, Group: "1"},
+			htmlBody,
+			false,
+			"csrf",
+		},
+		{
+			"regex-whole-match",
+			&Variable{From: FromTypeBodyRegex, Regex: `token-\d+`},
+			htmlBody,
+			false,
+			"token-123",
+		},
+		{
+			"regex-no-match",
+			&Variable{From: FromTypeBodyRegex, Regex: `nope-(\d+)`},
+			htmlBody,
+			true,
+			"",
+		},
+		{
+			"regex-unknown-group",
+			&Variable{From: FromTypeBodyRegex, Regex: `(\d+)`, Group: "missing"},
+			htmlBody,
+			true,
+			"",
+		},
+		{
+			"html-xpath-attribute",
+			&Variable{From: FromTypeBodyHtml, XPath: `//input[@name='csrf']/@value`},
+			htmlBody,
+			false,
+			"token-123",
+		},
+		{
+			"html-selector-attribute",
+			&Variable{From: FromTypeBodyHtml, Selector: `input[name=csrf]`, Attribute: "value"},
+			htmlBody,
+			false,
+			"token-123",
+		},
+		{
+			"html-selector-text",
+			&Variable{From: FromTypeBodyHtml, Selector: `h1.title`},
+			htmlBody,
+			false,
+			"Sign in",
+		},
+		{
+			"html-selector-no-match",
+			&Variable{From: FromTypeBodyHtml, Selector: `div.missing`},
+			htmlBody,
+			true,
+			"",
+		},
+		{
+			"xml-xpath-text",
+			&Variable{From: FromTypeBodyXml, XPath: `//User/Name`},
+			xmlBody,
+			false,
+			"bob",
+		},
+		{
+			"xml-xpath-attribute",
+			&Variable{From: FromTypeBodyXml, XPath: `//User/@id`},
+			xmlBody,
+			false,
+			"42",
+		},
+		{
+			"xml-xpath-count",
+			&Variable{From: FromTypeBodyXml, XPath: `count(//User)`},
+			xmlBody,
+			false,
+			"2",
+		},
+		{
+			"xml-xpath-no-match",
+			&Variable{From: FromTypeBodyXml, XPath: `//Missing`},
+			xmlBody,
+			true,
+			"",
+		},
+		{
+			"cookie",
+			&Variable{From: FromTypeCookie, JsonPath: "/session"},
+			"",
+			false,
+			"abc123",
+		},
+		{
+			"cookie-missing",
+			&Variable{From: FromTypeCookie, JsonPath: "missing"},
+			"",
+			true,
+			"",
+		},
+		{
+			"status-code",
+			&Variable{From: FromTypeStatusCode},
+			"",
+			false,
+			"201",
+		},
+		{
+			"response-time",
+			&Variable{From: FromTypeResponseTime},
+			"",
+			false,
+			"1500",
+		},
+		{
+			"final-url",
+			&Variable{From: FromTypeFinalUrl},
+			"",
+			false,
+			"https://example.com/final",
+		},
+	}
+
+	for _, testdata := range tests {
+		err := testdata.Var.ParseFromResult(context.Background(), newResult(testdata.Body))
+		if err == nil && testdata.ExpectErr {
+			t.Errorf("[%s] expected error but got none", testdata.TestName)
+			continue
+		}
+		if err != nil && !testdata.ExpectErr {
+			t.Errorf("[%s] got unexpected err: %s", testdata.TestName, err)
+			continue
+		}
+		if testdata.Var.Value != testdata.ExpectedValue {
+			t.Errorf("[%s] unexpected value. Got: '%s', expected: '%s'", testdata.TestName, testdata.Var.Value, testdata.ExpectedValue)
+		}
+	}
+}
+
+func TestVariable_validateSource(t *testing.T) {
+	tests := []struct {
+		TestName  string
+		Var       *Variable
+		ExpectErr bool
+	}{
+		{"regex", &Variable{From: FromTypeBodyRegex, Regex: `(?P<id>\d+)`, Group: "id"}, false},
+		{"invalid-regex", &Variable{From: FromTypeBodyRegex, Regex: `(\d+`}, true},
+		{"invalid-group", &Variable{From: FromTypeBodyRegex, Regex: `(\d+)`, Group: "2"}, true},
+		{"xml-requires-xpath", &Variable{From: FromTypeBodyXml}, true},
+		{"invalid-xpath", &Variable{From: FromTypeBodyXml, XPath: `//User[`}, true},
+		{"html-requires-xpath-or-selector", &Variable{From: FromTypeBodyHtml}, true},
+		{"invalid-selector", &Variable{From: FromTypeBodyHtml, Selector: `input[`}, true},
+		{"selector", &Variable{From: FromTypeBodyHtml, Selector: `input[name=csrf]`}, false},
+	}
+
+	for _, testdata := range tests {
+		errs := testdata.Var.validateSource(field.NewPath("var"))
+		if len(errs) == 0 && testdata.ExpectErr {
+			t.Errorf("[%s] expected error but got none", testdata.TestName)
+		}
+		if len(errs) > 0 && !testdata.ExpectErr {
+			t.Errorf("[%s] got unexpected err: %s", testdata.TestName, errs.ToAggregate())
+		}
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"github.com/oregondesignservices/monitoring-controller/internal/conf"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"strings"
+)
+
+func (h *HttpMonitor) isStrict() bool {
+	return h.Spec.Strict != nil && *h.Spec.Strict
+}
+
+// Variables that are defined before any request runs
+func (h *HttpMonitor) initialVariableNames() map[string]bool {
+	defined := make(map[string]bool)
+	for _, builtin := range BuiltinVariables {
+		defined[builtin.Name] = true
+	}
+	for _, globals := range []map[string]string{conf.GlobalConfig.GlobalRequestVars, conf.GlobalConfig.GlobalSecretRequestVars, h.Spec.Environment} {
+		for name := range globals {
+			defined[name] = true
+		}
+	}
+	for _, variable := range h.Spec.EnvironmentRefs {
+		defined[variable.Name] = true
+	}
+	return defined
+}
+
+// In strict mode, every variable a request references must be defined by `environment`, a built-in,
+// a --set-var or the `vars_from_response` of a request that runs earlier.
+// The keys of `env_from` are only known at execution time, so monitors using it are not checked.
+func (h *HttpMonitor) validateDefinedVariables(specPath *field.Path) field.ErrorList {
+	if !h.isStrict() || len(h.Spec.EnvFrom) > 0 {
+		return nil
+	}
+	var allErrs field.ErrorList
+
+	defined := h.initialVariableNames()
+	check := func(r *HttpRequest, path *field.Path) {
+		r.eachTemplateField(path, func(text string, path *field.Path) {
+			undefined := r.undefinedPlaceholders(text, defined)
+			if len(undefined) > 0 {
+				allErrs = append(allErrs, field.Invalid(path, text, "undefined variables: "+strings.Join(undefined, ", ")))
+			}
+		})
+		if r.ForEach != nil && !defined[r.ForEach.Variable] {
+			allErrs = append(allErrs, field.Invalid(path.Child("for_each", "variable"), r.ForEach.Variable, "not a defined variable"))
+		}
+		if r.DependsOn != nil {
+			for i, name := range r.DependsOn.Variables {
+				if !defined[name] {
+					allErrs = append(allErrs, field.Invalid(path.Child("depends_on", "variables").Index(i), name, "not a defined variable"))
+				}
+			}
+		}
+	}
+	define := func(r *HttpRequest) {
+		for _, variable := range r.VariablesFromResponse {
+			defined[variable.Name] = true
+		}
+	}
+
+	// Requests of a parallel group only see variables from before the group
+	i := 0
+	for _, step := range requestSteps(h.Spec.Requests) {
+		for j := range step {
+			check(&step[j], specPath.Child("requests").Index(i+j))
+		}
+		for j := range step {
+			define(&step[j])
+		}
+		i += len(step)
+	}
+	for i := range h.Spec.Cleanup {
+		check(&h.Spec.Cleanup[i], specPath.Child("cleanup").Index(i))
+		define(&h.Spec.Cleanup[i])
+	}
+
+	return allErrs
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"github.com/oregondesignservices/monitoring-controller/internal/conf"
+	"github.com/oregondesignservices/monitoring-controller/internal/httpclient"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestHttpMonitor_Default(t *testing.T) {
+	monitor := &HttpMonitor{}
+	monitor.Default()
+	if !monitor.isStrict() {
+		t.Errorf("expected new monitors to be strict")
+	}
+
+	strict := false
+	monitor.Spec.Strict = &strict
+	monitor.Default()
+	if monitor.isStrict() {
+		t.Errorf("expected strict: false to be kept")
+	}
+}
+
+func TestHttpMonitor_validateDefinedVariables(t *testing.T) {
+	conf.GlobalConfig.GlobalRequestVars["global"] = "value"
+	defer delete(conf.GlobalConfig.GlobalRequestVars, "global")
+
+	strict := true
+	tests := []struct {
+		TestName       string
+		Spec           HttpMonitorSpec
+		ExpectedErrors []string
+	}{
+		{
+			"defined",
+			HttpMonitorSpec{
+				Strict:          &strict,
+				Environment:     map[string]string{"host": "test.com"},
+				EnvironmentRefs: []EnvironmentVariable{{Name: "password"}},
+				Requests: []HttpRequest{
+					{
+						Url:                   "http://{host}/{global}/{uuid}",
+						Body:                  `{"password": "{password}"}`,
+						VariablesFromResponse: VariableList{&Variable{Name: "items"}},
+					},
+					{Url: "http://{host}/{item.id}", ForEach: &ForEach{Variable: "items"}},
+				},
+				Cleanup: []HttpRequest{
+					{Url: "http://{host}/{items}", DependsOn: &DependsOn{Variables: []string{"items"}}},
+				},
+			},
+			nil,
+		},
+		{
+			"undefined",
+			HttpMonitorSpec{
+				Strict: &strict,
+				Requests: []HttpRequest{
+					{Url: "http://test.com/{userid}"},
+					{
+						Headers:               http.Header{"Authorization": []string{"Bearer {tokn}"}},
+						VariablesFromResponse: VariableList{&Variable{Name: "userid"}},
+					},
+					{Url: "http://test.com/{item}", ForEach: &ForEach{Variable: "missing"}},
+				},
+				Cleanup: []HttpRequest{
+					{QueryParams: map[string][]string{"id": {"{userid}"}, "token": {"{token}"}}},
+				},
+			},
+			[]string{
+				"spec.cleanup[0].query_params[token]",
+				"spec.requests[0].url",
+				"spec.requests[1].headers[Authorization]",
+				"spec.requests[2].for_each.variable",
+			},
+		},
+		{
+			"parallel-group",
+			HttpMonitorSpec{
+				Strict: &strict,
+				Requests: []HttpRequest{
+					{ParallelGroup: "a", VariablesFromResponse: VariableList{&Variable{Name: "id"}}},
+					{ParallelGroup: "a", Url: "http://test.com/{id}"},
+					{Url: "http://test.com/{id}"},
+				},
+			},
+			[]string{"spec.requests[1].url"},
+		},
+		{
+			"not-strict",
+			HttpMonitorSpec{
+				Requests: []HttpRequest{{Url: "http://test.com/{userid}"}},
+			},
+			nil,
+		},
+		{
+			"env-from-is-not-checked",
+			HttpMonitorSpec{
+				Strict:   &strict,
+				EnvFrom:  []EnvironmentFromSource{{}},
+				Requests: []HttpRequest{{Url: "http://test.com/{userid}"}},
+			},
+			nil,
+		},
+	}
+
+	for _, testdata := range tests {
+		monitor := &HttpMonitor{Spec: testdata.Spec}
+		var fields []string
+		for _, err := range monitor.validateDefinedVariables(field.NewPath("spec")) {
+			fields = append(fields, err.Field)
+		}
+		// Headers and query params are maps, so their order is random
+		sort.Strings(fields)
+		if fmt.Sprint(fields) != fmt.Sprint(testdata.ExpectedErrors) {
+			t.Errorf("[%s] unexpected errors. Got: %v, expected: %v", testdata.TestName, fields, testdata.ExpectedErrors)
+		}
+	}
+}
+
+func TestHttpMonitor_ExecuteStrictTemplates(t *testing.T) {
+	httpclient.Initialize(time.Second)
+
+	var visited []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		visited = append(visited, r.URL.Path)
+	}))
+	defer server.Close()
+
+	strict, notStrict := true, false
+	tests := []struct {
+		TestName        string
+		Strict          *bool
+		ExpectedVisited []string
+		ExpectedError   string
+	}{
+		{"not-strict", &notStrict, []string{"/user/"}, ""},
+		{"strict", &strict, nil, "url: unresolved variables: userid"},
+	}
+
+	for _, testdata := range tests {
+		visited = nil
+		monitor := &HttpMonitor{Spec: HttpMonitorSpec{
+			Strict:         testdata.Strict,
+			TemplateEngine: TemplateEngineGo,
+			Requests: []HttpRequest{
+				{Name: "get user", Method: "GET", Url: server.URL + "/user/{{ .userid }}", ExpectedResponseCodes: []int{200}},
+			},
+		}}
+		result := monitor.Execute(context.Background(), nil, &RunnerState{})
+		if fmt.Sprint(visited) != fmt.Sprint(testdata.ExpectedVisited) {
+			t.Errorf("[%s] unexpected requests. Got: %v, expected: %v", testdata.TestName, visited, testdata.ExpectedVisited)
+		}
+		if len(result.Requests) != 1 || result.Requests[0].Error != testdata.ExpectedError {
+			t.Errorf("[%s] unexpected result: %+v", testdata.TestName, result.Requests)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// The functions available to templates when `template_engine` is "go"
+func templateFuncs(values map[string]string) template.FuncMap {
+	return template.FuncMap{
+		"b64enc":   func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+		"urlquery": url.QueryEscape,
+		"toJson": func(v interface{}) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+		"now":        time.Now,
+		"unixMillis": func() int64 { return time.Now().UnixNano() / int64(time.Millisecond) },
+		"uuid":       func() string { return uuid.New().String() },
+		// A random int in [min, max)
+		"randInt": func(min, max int) int { return min + rand.Intn(max-min) },
+		"hmacSha256": func(key, message string) string {
+			mac := hmac.New(sha256.New, []byte(key))
+			mac.Write([]byte(message))
+			return hex.EncodeToString(mac.Sum(nil))
+		},
+		"sha256": func(s string) string {
+			sum := sha256.Sum256([]byte(s))
+			return hex.EncodeToString(sum[:])
+		},
+		// Use as `{{ .name | default "fallback" }}`, or `{{ env "name" | default "fallback" }}` in strict mode
+		"default": func(fallback string, value interface{}) string {
+			if s, ok := value.(string); ok && s != "" {
+				return s
+			}
+			return fallback
+		},
+		// The value of a variable. Useful for names that are not valid template fields, ex: `{{ env "random-8" }}`
+		"env": func(name string) string { return values[name] },
+	}
+}
+
+func parseTemplate(name, text string, funcs template.FuncMap) (*template.Template, error) {
+	return template.New(name).Option("missingkey=zero").Funcs(funcs).Parse(text)
+}
+
+// Check that a template parses, without executing it
+func validateTemplate(text string) error {
+	_, err := parseTemplate("validate", text, templateFuncs(nil))
+	return err
+}
+
+var missingKeyRegex = regexp.MustCompile(`map has no entry for key "([^"]*)"`)
+
+// Substitutes variables into the parts of a request
+type renderer struct {
+	replacer *strings.Replacer
+	// nil unless the go template engine is used
+	values map[string]string
+	funcs  template.FuncMap
+	// Fail on `.name` fields of undefined variables, instead of rendering them empty
+	failOnUnresolved bool
+}
+
+func newRenderer(variables VariableList, engine TemplateEngine, failOnUnresolved bool) *renderer {
+	r := &renderer{
+		replacer:         variables.newReplacer(),
+		failOnUnresolved: failOnUnresolved,
+	}
+	if engine == TemplateEngineGo {
+		r.values = variables.values()
+		r.funcs = templateFuncs(r.values)
+	}
+	return r
+}
+
+// Execute the template, if enabled, then replace `{name}` variables
+func (r *renderer) render(text string) (string, error) {
+	if r.values != nil && strings.Contains(text, "{{") {
+		tmpl, err := parseTemplate("request", text, r.funcs)
+		if err != nil {
+			return "", err
+		}
+		if r.failOnUnresolved {
+			tmpl.Option("missingkey=error")
+		}
+		var buf bytes.Buffer
+		err = tmpl.Execute(&buf, r.values)
+		if err != nil {
+			// Reported the same way as unresolved `{name}` placeholders
+			if match := missingKeyRegex.FindStringSubmatch(err.Error()); match != nil {
+				return "", fmt.Errorf("unresolved variables: %s", match[1])
+			}
+			return "", err
+		}
+		text = buf.String()
+	}
+	return r.replacer.Replace(text), nil
+}
+
+// The template engine used by a request. A request's own engine overrides the monitor's.
+func (h *HttpMonitor) templateEngineFor(r *HttpRequest) TemplateEngine {
+	if r.TemplateEngine != "" {
+		return r.TemplateEngine
+	}
+	return h.Spec.TemplateEngine
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestRenderer_render(t *testing.T) {
+	variables := VariableList{
+		&Variable{Name: "user", Value: "bob"},
+		&Variable{Name: "password", Value: "p@ss word"},
+		&Variable{Name: "random-8", Value: "abcdefgh"},
+		&Variable{Name: "user", Value: "shadowed"},
+	}
+
+	tests := []struct {
+		TestName       string
+		Engine         TemplateEngine
+		Input          string
+		ExpectErr      bool
+		ExpectedOutput string
+		ExpectedRegex  string
+	}{
+		{
+			"simple-leaves-templates-alone",
+			TemplateEngineSimple,
+			"{{ .user }} {user}",
+			false,
+			"{{ .user }} bob",
+			"",
+		},
+		{
+			"go-keeps-simple-syntax",
+			TemplateEngineGo,
+			"/users/{user}",
+			false,
+			"/users/bob",
+			"",
+		},
+		{
+			"go-field",
+			TemplateEngineGo,
+			"{{ .user }}-{random-8}",
+			false,
+			"bob-abcdefgh",
+			"",
+		},
+		{
+			"b64enc",
+			TemplateEngineGo,
+			`{{ printf "%s:%s" .user .password | b64enc }}`,
+			false,
+			"Ym9iOnBAc3Mgd29yZA==",
+			"",
+		},
+		{
+			"urlquery",
+			TemplateEngineGo,
+			"{{ .password | urlquery }}",
+			false,
+			"p%40ss+word",
+			"",
+		},
+		{
+			"toJson",
+			TemplateEngineGo,
+			`{"user": {{ toJson .user }}}`,
+			false,
+			`{"user": "bob"}`,
+			"",
+		},
+		{
+			"default",
+			TemplateEngineGo,
+			`{{ .missing | default "fallback" }} {{ .user | default "fallback" }}`,
+			false,
+			"fallback bob",
+			"",
+		},
+		{
+			"env",
+			TemplateEngineGo,
+			`{{ env "random-8" }}`,
+			false,
+			"abcdefgh",
+			"",
+		},
+		{
+			"sha256",
+			TemplateEngineGo,
+			"{{ sha256 .user }}",
+			false,
+			"81b637d8fcd2c6da6359e6963113a1170de795e4b725b84d1e0b4cfd9ec58ce9",
+			"",
+		},
+		{
+			"hmacSha256",
+			TemplateEngineGo,
+			`{{ hmacSha256 "key" "The quick brown fox jumps over the lazy dog" }}`,
+			false,
+			"f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8",
+			"",
+		},
+		{
+			"uuid",
+			TemplateEngineGo,
+			"{{ uuid }}",
+			false,
+			"",
+			"^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$",
+		},
+		{
+			"randInt",
+			TemplateEngineGo,
+			"{{ randInt 5 10 }}",
+			false,
+			"",
+			"^[5-9]$",
+		},
+		{
+			"now-and-unixMillis",
+			TemplateEngineGo,
+			`{{ now.Format "2006" }} {{ unixMillis }}`,
+			false,
+			"",
+			`^\d{4} \d{13}$`,
+		},
+		{
+			"parse-error",
+			TemplateEngineGo,
+			"{{ .user ",
+			true,
+			"",
+			"",
+		},
+		{
+			"unknown-function",
+			TemplateEngineGo,
+			"{{ b64dec .user }}",
+			true,
+			"",
+			"",
+		},
+	}
+
+	for _, testdata := range tests {
+		output, err := newRenderer(variables, testdata.Engine, false).render(testdata.Input)
+		if err == nil && testdata.ExpectErr {
+			t.Errorf("[%s] expected error but got none", testdata.TestName)
+			continue
+		}
+		if err != nil && !testdata.ExpectErr {
+			t.Errorf("[%s] got unexpected err: %s", testdata.TestName, err)
+			continue
+		}
+		if testdata.ExpectedRegex != "" {
+			if !regexp.MustCompile(testdata.ExpectedRegex).MatchString(output) {
+				t.Errorf("[%s] output '%s' does not match %s", testdata.TestName, output, testdata.ExpectedRegex)
+			}
+			continue
+		}
+		if output != testdata.ExpectedOutput {
+			t.Errorf("[%s] unexpected output. Got: %s, expected: %s", testdata.TestName, output, testdata.ExpectedOutput)
+		}
+	}
+}
+
+func TestRenderer_failOnUnresolved(t *testing.T) {
+	variables := VariableList{&Variable{Name: "user", Value: "alice"}}
+	tests := []struct {
+		TestName         string
+		FailOnUnresolved bool
+		Input            string
+		ExpectedOutput   string
+		ExpectedErr      string
+	}{
+		{"missing-key-is-empty", false, "/user/{{ .userid }}", "/user/", ""},
+		{"missing-key-fails", true, "/user/{{ .userid }}", "", "unresolved variables: userid"},
+		{"defined-key", true, "/user/{{ .user }}", "/user/alice", ""},
+		// env is the way to use an optional variable
+		{"optional-variable", true, `/user/{{ env "userid" | default "none" }}`, "/user/none", ""},
+	}
+
+	for _, testdata := range tests {
+		output, err := newRenderer(variables, TemplateEngineGo, testdata.FailOnUnresolved).render(testdata.Input)
+		if testdata.ExpectedErr != "" {
+			if err == nil || err.Error() != testdata.ExpectedErr {
+				t.Errorf("[%s] Got err: %v, expected: %s", testdata.TestName, err, testdata.ExpectedErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("[%s] got unexpected err: %s", testdata.TestName, err)
+			continue
+		}
+		if output != testdata.ExpectedOutput {
+			t.Errorf("[%s] unexpected output. Got: %s, expected: %s", testdata.TestName, output, testdata.ExpectedOutput)
+		}
+	}
+}
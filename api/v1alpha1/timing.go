@@ -0,0 +1,156 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// How long each phase of a request took. Phases that did not happen (ex: DNS on a reused connection) are zero.
+// +kubebuilder:object:generate=false
+type RequestTimings struct {
+	DNS          time.Duration
+	Connect      time.Duration
+	TLSHandshake time.Duration
+	FirstByte    time.Duration
+	BodyTransfer time.Duration
+	Total        time.Duration
+}
+
+// Phase name to duration, as used by the phase histogram
+func (t *RequestTimings) Phases() map[string]time.Duration {
+	return map[string]time.Duration{
+		"dns":           t.DNS,
+		"connect":       t.Connect,
+		"tls_handshake": t.TLSHandshake,
+		"first_byte":    t.FirstByte,
+		"body_transfer": t.BodyTransfer,
+	}
+}
+
+// Records the phase timings of a single request. The trace callbacks can run concurrently, for example when
+// IPv4 and IPv6 are dialed in parallel, and can still run after the request is done, so fields are guarded by lock.
+type timingTracer struct {
+	lock    sync.Mutex
+	timings *RequestTimings
+	// Set by done. Later callbacks, such as a losing parallel dial finishing, are ignored.
+	finished bool
+
+	start    time.Time
+	dnsStart time.Time
+	// By network and address, since several dials can be in progress
+	connectStarts map[string]time.Time
+	connected     bool
+	tlsStart      time.Time
+	firstByte     time.Time
+}
+
+func newTimingTracer(timings *RequestTimings) *timingTracer {
+	return &timingTracer{timings: timings, connectStarts: make(map[string]time.Time)}
+}
+
+// Run f with the lock held, unless the request is done
+func (t *timingTracer) record(f func()) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !t.finished {
+		f()
+	}
+}
+
+func (t *timingTracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.record(func() {
+				if t.dnsStart.IsZero() {
+					t.dnsStart = time.Now()
+				}
+			})
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.record(func() {
+				if t.timings.DNS == 0 {
+					t.timings.DNS = time.Since(t.dnsStart)
+				}
+			})
+		},
+		ConnectStart: func(network, addr string) {
+			t.record(func() {
+				t.connectStarts[network+" "+addr] = time.Now()
+			})
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.record(func() {
+				// Only the first successful dial is the one used, other parallel dials are cancelled or closed
+				connectStart, ok := t.connectStarts[network+" "+addr]
+				if err != nil || !ok || t.connected {
+					return
+				}
+				t.connected = true
+				t.timings.Connect = time.Since(connectStart)
+			})
+		},
+		TLSHandshakeStart: func() {
+			t.record(func() {
+				t.tlsStart = time.Now()
+			})
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.record(func() {
+				t.timings.TLSHandshake = time.Since(t.tlsStart)
+			})
+		},
+		GotFirstResponseByte: func() {
+			t.record(func() {
+				t.firstByte = time.Now()
+				t.timings.FirstByte = t.firstByte.Sub(t.start)
+			})
+		},
+	}
+}
+
+func (t *timingTracer) begin() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.start = time.Now()
+}
+
+// Called once the body has been fully read. The timings are not changed after this.
+func (t *timingTracer) done() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.finished {
+		return
+	}
+	t.finished = true
+	now := time.Now()
+	if !t.firstByte.IsZero() {
+		t.timings.BodyTransfer = now.Sub(t.firstByte)
+	}
+	t.timings.Total = now.Sub(t.start)
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/oregondesignservices/monitoring-controller/internal/httpclient"
+	"time"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Resolve the referenced Secrets and ConfigMaps into a client config
+func (t *TLSConfig) clientConfig(ctx context.Context, fetcher *resourceFetcher) (httpclient.Config, error) {
+	config := httpclient.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.MinVersion != "" {
+		version, ok := tlsVersions[t.MinVersion]
+		if !ok {
+			return config, fmt.Errorf("not a known TLS version: %s", t.MinVersion)
+		}
+		config.MinVersion = version
+	}
+
+	if t.CA != nil {
+		ca, _, _, err := fetcher.value(ctx, t.CA)
+		if err != nil {
+			return config, err
+		}
+		config.CACert = []byte(ca)
+	}
+
+	if (t.ClientCert == nil) != (t.ClientKey == nil) {
+		return config, errors.New("tls client_cert and client_key must be set together")
+	}
+	if t.ClientCert != nil {
+		cert, _, err := fetcher.secretKey(ctx, t.ClientCert)
+		if err != nil {
+			return config, err
+		}
+		key, _, err := fetcher.secretKey(ctx, t.ClientKey)
+		if err != nil {
+			return config, err
+		}
+		config.ClientCert = []byte(cert)
+		config.ClientKey = []byte(key)
+	}
+
+	return config, nil
+}
+
+// A certificate expiring within `min_cert_validity` is a failure
+func (r *HttpRequest) checkCertificates(info *TLSInfo) error {
+	minValidity, err := parseDurationOrDefault(r.MinCertValidity, 0)
+	if err != nil {
+		return err
+	}
+	if minValidity <= 0 || info == nil {
+		return nil
+	}
+	earliest := info.EarliestExpiry()
+	if earliest == nil {
+		return nil
+	}
+	remaining := time.Until(earliest.NotAfter)
+	if remaining < minValidity {
+		return fmt.Errorf("certificate '%s' expires in %s, which is less than min_cert_validity %s",
+			earliest.Subject, remaining.Round(time.Second), minValidity)
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"github.com/oregondesignservices/monitoring-controller/internal/httpclient"
+	"github.com/oregondesignservices/monitoring-controller/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+	"time"
+)
+
+// A self signed certificate and key, PEM encoded
+func newTestKeyPair(t *testing.T) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %s", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %s", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func TestHttpMonitor_httpClientTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	serverCA := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	clientCert, clientKey := newTestKeyPair(t)
+
+	reader := fake.NewFakeClientWithScheme(scheme.Scheme,
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "ca"},
+			Data:       map[string]string{"ca.crt": string(serverCA)},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "client"},
+			Data: map[string][]byte{
+				"tls.crt": clientCert,
+				"tls.key": clientKey,
+			},
+		},
+	)
+	caSource := &VariableSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "ca"},
+		Key:                  "ca.crt",
+	}}
+
+	tests := []struct {
+		TestName       string
+		TLS            *TLSConfig
+		ExpectErr      bool
+		ExpectedStatus int
+	}{
+		{
+			"unknown-ca",
+			&TLSConfig{},
+			true,
+			0,
+		},
+		{
+			"custom-ca",
+			&TLSConfig{CA: caSource},
+			false,
+			http.StatusUnauthorized,
+		},
+		{
+			"mutual-tls",
+			&TLSConfig{
+				CA:         caSource,
+				ClientCert: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "client"}, Key: "tls.crt"},
+				ClientKey:  &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "client"}, Key: "tls.key"},
+			},
+			false,
+			http.StatusOK,
+		},
+		{
+			"insecure-skip-verify",
+			&TLSConfig{InsecureSkipVerify: true},
+			false,
+			http.StatusUnauthorized,
+		},
+		{
+			"wrong-server-name",
+			&TLSConfig{CA: caSource, ServerName: "not-real.test"},
+			true,
+			0,
+		},
+	}
+
+	for _, testdata := range tests {
+		monitor := &HttpMonitor{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "monitor"},
+			Spec:       HttpMonitorSpec{TLS: testdata.TLS},
+		}
+		client, err := monitor.httpClient(context.Background(), newResourceFetcher(reader, "ns"), &httpclient.Ref{})
+		if err != nil {
+			t.Errorf("[%s] failed to build client: %s", testdata.TestName, err)
+			continue
+		}
+		resp, err := client.Get(server.URL)
+		if err == nil && testdata.ExpectErr {
+			t.Errorf("[%s] expected error but got none", testdata.TestName)
+			continue
+		}
+		if err != nil && !testdata.ExpectErr {
+			t.Errorf("[%s] got unexpected err: %s", testdata.TestName, err)
+			continue
+		}
+		if resp != nil && resp.StatusCode != testdata.ExpectedStatus {
+			t.Errorf("[%s] unexpected status. Got: %d, expected: %d", testdata.TestName, resp.StatusCode, testdata.ExpectedStatus)
+		}
+	}
+}
+
+func TestHttpRequest_sendRequestMinCertValidity(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	r := &HttpRequest{
+		Method:                "GET",
+		Url:                   server.URL,
+		ExpectedResponseCodes: []int{200},
+		MinCertValidity:       "24h",
+	}
+
+	result, err := r.sendRequest(context.Background(), server.Client())
+	if err != nil {
+		t.Fatalf("got unexpected err: %s", err)
+	}
+	if result.TLS == nil || len(result.TLS.Certificates) == 0 {
+		t.Fatalf("expected tls details to be recorded")
+	}
+	if !result.TLS.Certificates[0].NotAfter.Equal(server.Certificate().NotAfter) {
+		t.Errorf("unexpected leaf expiry: %s", result.TLS.Certificates[0].NotAfter)
+	}
+
+	// Longer than the test certificate is valid
+	r.MinCertValidity = "1000000h"
+	_, err = r.sendRequest(context.Background(), server.Client())
+	if err == nil {
+		t.Errorf("expected min_cert_validity to fail the request")
+	}
+}
+
+// The number of TLS expiry series of a monitor
+func countTLSCertExpirySeries(crd string) int {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		metrics.TLSCertExpiryGauge.Collect(ch)
+		close(ch)
+	}()
+	count := 0
+	for m := range ch {
+		pb := &dto.Metric{}
+		_ = m.Write(pb)
+		for _, pair := range pb.GetLabel() {
+			if pair.GetName() == "crd" && pair.GetValue() == crd {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func TestHandleMetrics_TLSCertExpiry(t *testing.T) {
+	monitor := &HttpMonitor{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "tls-metrics"}}
+	req := HttpRequest{Name: "req", Url: "https://test.com"}
+	chain := &RequestResult{TLS: &TLSInfo{
+		Version:    tls.VersionTLS12,
+		ServerName: "test.com",
+		Certificates: []CertificateInfo{
+			{Subject: "CN=test.com", Issuer: "CN=intermediate", DNSNames: []string{"test.com"}, NotAfter: time.Now().Add(time.Hour)},
+			{Subject: "CN=intermediate", Issuer: "CN=root", NotAfter: time.Now().Add(2 * time.Hour)},
+		},
+	}}
+
+	HandleMetrics(monitor, req, chain, nil)
+	if count := countTLSCertExpirySeries("ns/tls-metrics"); count != 2 {
+		t.Errorf("expected a series per certificate, got %d", count)
+	}
+
+	// The server stopped sending the intermediate
+	leafOnly := &RequestResult{TLS: &TLSInfo{Certificates: chain.TLS.Certificates[:1]}}
+	HandleMetrics(monitor, req, leafOnly, nil)
+	if count := countTLSCertExpirySeries("ns/tls-metrics"); count != 1 {
+		t.Errorf("expected the old chain series to be deleted, got %d series", count)
+	}
+
+	// A failed handshake keeps the last known expiry
+	HandleMetrics(monitor, req, &RequestResult{}, errors.New("tls: handshake failure"))
+	if count := countTLSCertExpirySeries("ns/tls-metrics"); count != 1 {
+		t.Errorf("expected the leaf series to be kept, got %d series", count)
+	}
+
+	RemoveMetrics("ns", "tls-metrics")
+	if count := countTLSCertExpirySeries("ns/tls-metrics"); count != 0 {
+		t.Errorf("expected all series to be removed, got %d series", count)
+	}
+
+	status := newRequestStatus(req.Name, time.Now(), chain, nil)
+	if status.TLS == nil {
+		t.Fatalf("expected tls details in the status")
+	}
+	if status.TLS.Version != "1.2" || status.TLS.ServerName != "test.com" || status.TLS.Issuer != "CN=intermediate" ||
+		len(status.TLS.DNSNames) != 1 || status.TLS.DNSNames[0] != "test.com" {
+		t.Errorf("unexpected tls details: %+v", status.TLS)
+	}
+}
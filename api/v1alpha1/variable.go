@@ -26,6 +26,7 @@ package v1alpha1
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/ghodss/yaml"
@@ -34,6 +35,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // split for easier unittesting
@@ -53,6 +55,82 @@ func (v VariableList) newReplacer() *strings.Replacer {
 	return strings.NewReplacer(args...)
 }
 
+// The value of each variable by name. Like substitution, the first variable with a name wins.
+func (v VariableList) values() map[string]string {
+	values := make(map[string]string, len(v))
+	for _, variable := range v {
+		if _, ok := values[variable.Name]; !ok {
+			values[variable.Name] = variable.Value
+		}
+	}
+	return values
+}
+
+// Adds the variables from `other`, replacing existing variables with the same name.
+// Replacement matters because the first matching variable is the one used in substitution.
+func (v VariableList) merge(other VariableList) VariableList {
+	for _, variable := range other {
+		replaced := false
+		for i, existing := range v {
+			if existing.Name == variable.Name {
+				v[i] = variable
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			v = append(v, variable)
+		}
+	}
+	return v
+}
+
+const redacted = "<redacted>"
+
+// Replace the values of secret variables in a string, so it is safe to log
+func (v VariableList) redact(s string) string {
+	var replacerArgs []string
+	for _, variable := range v {
+		if variable.Secret && variable.Value != "" {
+			replacerArgs = append(replacerArgs, variable.Value, redacted)
+		}
+	}
+	if len(replacerArgs) == 0 {
+		return s
+	}
+	return strings.NewReplacer(replacerArgs...).Replace(s)
+}
+
+// Same as redact, but for errors. Assertion errors keep their type for metrics.
+func (v VariableList) redactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var assertionErr *AssertionError
+	if errors.As(err, &assertionErr) {
+		return &AssertionError{
+			Assertion: assertionErr.Assertion,
+			Message:   v.redact(assertionErr.Message),
+		}
+	}
+	return errors.New(v.redact(err.Error()))
+}
+
+// The value a variable or assertion points to is not in the response. Other errors while parsing mean
+// the response could not be read, like an HTML error page instead of JSON.
+// +kubebuilder:object:generate=false
+type NotFoundError struct {
+	Message string
+}
+
+func (e *NotFoundError) Error() string {
+	return e.Message
+}
+
+func notFound(format string, args ...interface{}) error {
+	return &NotFoundError{Message: fmt.Sprintf(format, args...)}
+}
+
 // Clears all values that are not provided by users
 func (v VariableList) clearValues() {
 	for _, elem := range v {
@@ -71,6 +149,21 @@ func readBodyAndReset(resp *http.Response) []byte {
 	return bodyBytes
 }
 
+// Same as ParseFromResponse, but also supports response_time and cookies from the cookie jar
+func (v *Variable) ParseFromResult(ctx context.Context, result *RequestResult) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	switch v.From {
+	case FromTypeResponseTime:
+		v.Value = strconv.FormatInt(int64(result.Timings.Total/time.Millisecond), 10)
+		return nil
+	case FromTypeCookie:
+		return v.parseFromCookie(result.Response, result.Cookies)
+	}
+	return v.ParseFromResponse(result.Response)
+}
+
 func (v *Variable) ParseFromResponse(resp *http.Response) error {
 	switch v.From {
 	case FromTypeProvided:
@@ -84,6 +177,21 @@ func (v *Variable) ParseFromResponse(resp *http.Response) error {
 		return v.parseFromBodyRaw(resp)
 	case FromTypeHeaders:
 		return v.parseFromHeaders(resp)
+	case FromTypeBodyRegex:
+		return v.parseFromBodyRegex(resp)
+	case FromTypeBodyXml:
+		return v.parseFromBodyXml(resp)
+	case FromTypeBodyHtml:
+		return v.parseFromBodyHtml(resp)
+	case FromTypeCookie:
+		return v.parseFromCookie(resp, nil)
+	case FromTypeStatusCode:
+		v.Value = strconv.Itoa(resp.StatusCode)
+		return nil
+	case FromTypeFinalUrl:
+		return v.parseFromFinalUrl(resp)
+	case FromTypeResponseTime:
+		return errors.New("response_time is only available while handling a request")
 	}
 	return fmt.Errorf("not a known variable 'from' type: %s", v.From)
 }
@@ -101,6 +209,16 @@ func (v *Variable) jsonPathToPieces() []string {
 }
 
 func (v *Variable) parseFromJsonBytes(jsonBody []byte) error {
+	var err error
+	switch v.ExpressionType {
+	case ExpressionTypeJsonPath:
+		v.Value, err = evaluateJsonPath(v.JsonPath, jsonBody)
+		return err
+	case ExpressionTypeJmesPath:
+		v.Value, err = evaluateJmesPath(v.JsonPath, jsonBody)
+		return err
+	}
+
 	jsonPath := v.jsonPathToPieces()
 
 	// jsoniter.Get needs a specific type. So convert to that.
@@ -114,10 +232,13 @@ func (v *Variable) parseFromJsonBytes(jsonBody []byte) error {
 		}
 	}
 
+	if !jsoniter.Valid(jsonBody) {
+		return errors.New("body is not valid json")
+	}
 	getter := jsoniter.Get(jsonBody, interfaceJsonPath...)
-	err := getter.LastError()
+	err = getter.LastError()
 	if err != nil {
-		return err
+		return notFound("%s", err)
 	}
 	v.Value = getter.ToString()
 	return nil
@@ -166,7 +287,7 @@ func (v *Variable) parseFromHeaders(resp *http.Response) error {
 			return err
 		}
 		values := resp.Header.Values(pieces[0])
-		if len(values) >= index {
+		if index >= 0 && index < len(values) {
 			v.Value = values[index]
 		}
 	default:
@@ -174,7 +295,7 @@ func (v *Variable) parseFromHeaders(resp *http.Response) error {
 	}
 
 	if v.Value == "" {
-		return fmt.Errorf("not a known header jsonpath: %s", v.JsonPath)
+		return notFound("not a known header jsonpath: %s", v.JsonPath)
 	}
 
 	return nil
@@ -106,6 +106,36 @@ func TestVariable_ParseFromResponse(t *testing.T) {
 			true,
 			"",
 		},
+		{
+			"header-index-out-of-range",
+			&Variable{
+				Name:     "test",
+				From:     FromTypeHeaders,
+				JsonPath: "/My-Header/1",
+			},
+			&http.Response{
+				Header: http.Header{
+					"My-Header": []string{"val"},
+				},
+			},
+			true,
+			"",
+		},
+		{
+			"header-negative-index",
+			&Variable{
+				Name:     "test",
+				From:     FromTypeHeaders,
+				JsonPath: "/My-Header/-1",
+			},
+			&http.Response{
+				Header: http.Header{
+					"My-Header": []string{"val"},
+				},
+			},
+			true,
+			"",
+		},
 		// JSON tests
 		{
 			"json-simple",
@@ -0,0 +1,135 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Functions for `when` expressions that inspect the current run
+func (e *execution) whenFuncs() template.FuncMap {
+	funcs := templateFuncs(e.available().values())
+
+	funcs["defined"] = e.defined
+	// 0 if the request did not run or got no response
+	funcs["status"] = func(name string) int {
+		if status := e.latestStatus(name); status != nil {
+			return status.StatusCode
+		}
+		return 0
+	}
+	funcs["succeeded"] = e.succeeded
+	funcs["failed"] = func(name string) bool {
+		status := e.latestStatus(name)
+		return status != nil && status.Error != ""
+	}
+	funcs["skipped"] = func(name string) bool {
+		status := e.latestStatus(name)
+		return status != nil && status.Skipped != ""
+	}
+	return funcs
+}
+
+// The latest status of a request in this run
+func (e *execution) latestStatus(name string) *RequestStatus {
+	if e.result == nil {
+		return nil
+	}
+	for _, statuses := range [][]RequestStatus{e.result.Cleanup, e.result.Requests} {
+		for i := len(statuses) - 1; i >= 0; i-- {
+			if statuses[i].Name == name {
+				return &statuses[i]
+			}
+		}
+	}
+	return nil
+}
+
+// Whether a variable is defined and not empty
+func (e *execution) defined(name string) bool {
+	for _, variable := range e.available() {
+		if variable.Name == name && variable.Value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether a request ran in this run and succeeded
+func (e *execution) succeeded(name string) bool {
+	status := e.latestStatus(name)
+	return status != nil && status.Skipped == "" && status.Error == ""
+}
+
+// Expressions may leave out the braces, ex: `not (defined "token")`
+func whenTemplate(when string) string {
+	if strings.Contains(when, "{{") {
+		return when
+	}
+	return "{{ " + when + " }}"
+}
+
+// Check that a `when` expression parses, without evaluating it
+func validateWhen(when string) error {
+	_, err := parseTemplate("when", whenTemplate(when), (&execution{}).whenFuncs())
+	return err
+}
+
+// Whether a request should run, based on its `when` expression
+func (e *execution) shouldRun(r *HttpRequest) (bool, error) {
+	if r.When == "" {
+		return true, nil
+	}
+	tmpl, err := parseTemplate("when", whenTemplate(r.When), e.whenFuncs())
+	if err != nil {
+		return false, fmt.Errorf("when: %w", err)
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, e.available().values())
+	if err != nil {
+		return false, fmt.Errorf("when: %w", err)
+	}
+
+	// The result is not part of the error, since it may contain the value of a secret variable
+	switch strings.TrimSpace(buf.String()) {
+	case "true":
+		return true, nil
+	case "false", "":
+		return false, nil
+	default:
+		return false, errors.New("when must evaluate to true or false")
+	}
+}
+
+func (r *HttpRequest) onFailure() OnFailure {
+	if r.OnFailure == "" {
+		return OnFailureCleanup
+	}
+	return r.OnFailure
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"context"
+	"github.com/oregondesignservices/monitoring-controller/internal/httpclient"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecution_shouldRun(t *testing.T) {
+	e := &execution{
+		variables: VariableList{
+			&Variable{Name: "token", Value: "abc"},
+			&Variable{Name: "empty", Value: ""},
+		},
+		result: &ExecutionResult{
+			Requests: []RequestStatus{
+				{Name: "get user", StatusCode: 404, Error: "not an expected error code"},
+				{Name: "create user", StatusCode: 201},
+				{Name: "optional", Skipped: "when is false"},
+			},
+		},
+	}
+
+	tests := []struct {
+		TestName    string
+		When        string
+		ExpectErr   bool
+		ExpectedRun bool
+	}{
+		{"empty", "", false, true},
+		{"defined", `defined "token"`, false, true},
+		{"not-defined", `not (defined "empty")`, false, true},
+		{"unknown-variable", `defined "missing"`, false, false},
+		{"status", `eq (status "get user") 404`, false, true},
+		{"status-not-run", `eq (status "delete user") 0`, false, true},
+		{"succeeded", `succeeded "create user"`, false, true},
+		{"failed", `failed "get user"`, false, true},
+		{"skipped", `skipped "optional"`, false, true},
+		{"skipped-is-not-success", `succeeded "optional"`, false, false},
+		{"with-braces", `{{ if eq .token "abc" }}true{{ else }}false{{ end }}`, false, true},
+		{"not-a-bool", `.token`, true, false},
+		{"parse-error", `eq (status "get user"`, true, false},
+	}
+
+	for _, testdata := range tests {
+		run, err := e.shouldRun(&HttpRequest{When: testdata.When})
+		if err == nil && testdata.ExpectErr {
+			t.Errorf("[%s] expected error but got none", testdata.TestName)
+			continue
+		}
+		if err != nil && !testdata.ExpectErr {
+			t.Errorf("[%s] got unexpected err: %s", testdata.TestName, err)
+			continue
+		}
+		if run != testdata.ExpectedRun {
+			t.Errorf("[%s] unexpected result. Got: %t, expected: %t", testdata.TestName, run, testdata.ExpectedRun)
+		}
+	}
+}
+
+func TestExecution_runRequestRedactsWhen(t *testing.T) {
+	e := &execution{
+		variables: VariableList{
+			&Variable{Name: "password", Value: "hunter2", Secret: true},
+		},
+		result: &ExecutionResult{},
+	}
+
+	status, _, err := e.runRequest(HttpRequest{Name: "login", When: `.password`})
+	if err == nil {
+		t.Fatalf("expected error but got none")
+	}
+	if strings.Contains(err.Error(), "hunter2") || strings.Contains(status.Error, "hunter2") {
+		t.Errorf("secret value not redacted: %s", status.Error)
+	}
+}
+
+func TestHttpMonitor_ExecuteOnFailure(t *testing.T) {
+	httpclient.Initialize(time.Second)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	request := func(name, path string, onFailure OnFailure) HttpRequest {
+		return HttpRequest{
+			Name:                  name,
+			Method:                "GET",
+			Url:                   server.URL + path,
+			ExpectedResponseCodes: []int{200},
+			OnFailure:             onFailure,
+		}
+	}
+
+	tests := []struct {
+		TestName         string
+		OnFailure        OnFailure
+		ExpectedRequests int
+		ExpectedCleanup  int
+	}{
+		{"default-runs-cleanup", "", 1, 1},
+		{"cleanup", OnFailureCleanup, 1, 1},
+		{"stop", OnFailureStop, 1, 0},
+		{"continue", OnFailureContinue, 2, 1},
+	}
+
+	for _, testdata := range tests {
+		monitor := &HttpMonitor{Spec: HttpMonitorSpec{
+			Requests: []HttpRequest{
+				request("fails", "/missing", testdata.OnFailure),
+				request("next", "/", ""),
+			},
+			Cleanup: []HttpRequest{
+				request("cleanup", "/", ""),
+			},
+		}}
+
+		result := monitor.Execute(context.Background(), nil, &RunnerState{})
+		if !result.Failed() {
+			t.Errorf("[%s] expected the run to fail", testdata.TestName)
+		}
+		if len(result.Requests) != testdata.ExpectedRequests {
+			t.Errorf("[%s] unexpected requests. Got: %d, expected: %d", testdata.TestName, len(result.Requests), testdata.ExpectedRequests)
+		}
+		if len(result.Cleanup) != testdata.ExpectedCleanup {
+			t.Errorf("[%s] unexpected cleanup. Got: %d, expected: %d", testdata.TestName, len(result.Cleanup), testdata.ExpectedCleanup)
+		}
+	}
+}
+
+func TestHttpMonitor_ExecuteCreateIfNotExists(t *testing.T) {
+	httpclient.Initialize(time.Second)
+
+	exists := false
+	created := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		case "POST":
+			created++
+			exists = true
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer server.Close()
+
+	monitor := &HttpMonitor{Spec: HttpMonitorSpec{
+		Requests: []HttpRequest{
+			{Name: "get user", Method: "GET", Url: server.URL, ExpectedResponseCodes: []int{200, 404}},
+			{Name: "create user", Method: "POST", Url: server.URL, ExpectedResponseCodes: []int{201},
+				When: `eq (status "get user") 404`},
+		},
+	}}
+
+	for run := 1; run <= 2; run++ {
+		result := monitor.Execute(context.Background(), nil, &RunnerState{})
+		if result.Failed() {
+			t.Errorf("run %d: unexpected failure: %s", run, result.FirstFailure().Error)
+		}
+	}
+	if created != 1 {
+		t.Errorf("expected the user to be created once, got: %d", created)
+	}
+}
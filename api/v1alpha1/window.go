@@ -0,0 +1,161 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"fmt"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var weekdays = map[Weekday]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Parse HH:MM into minutes since midnight
+func parseClock(clock string) (int, error) {
+	pieces := strings.Split(clock, ":")
+	if len(pieces) != 2 || len(pieces[0]) != 2 || len(pieces[1]) != 2 {
+		return 0, fmt.Errorf("not a HH:MM time: %s", clock)
+	}
+	hours, err := strconv.Atoi(pieces[0])
+	if err != nil || hours < 0 || hours > 23 {
+		return 0, fmt.Errorf("not a HH:MM time: %s", clock)
+	}
+	minutes, err := strconv.Atoi(pieces[1])
+	if err != nil || minutes < 0 || minutes > 59 {
+		return 0, fmt.Errorf("not a HH:MM time: %s", clock)
+	}
+	return hours*60 + minutes, nil
+}
+
+func (w *TimeWindow) startsOn(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, weekday := range w.Days {
+		if weekdays[weekday] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Used by the webhook, and before checking a window at execution time
+func (w *TimeWindow) validate(path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if _, err := parseClock(w.Start); err != nil {
+		allErrs = append(allErrs, field.Invalid(path.Child("start"), w.Start, err.Error()))
+	}
+	if _, err := parseClock(w.End); err != nil {
+		allErrs = append(allErrs, field.Invalid(path.Child("end"), w.End, err.Error()))
+	}
+	for i, day := range w.Days {
+		if _, ok := weekdays[day]; !ok {
+			allErrs = append(allErrs, field.NotSupported(path.Child("days").Index(i), day,
+				[]string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}))
+		}
+	}
+	return allErrs
+}
+
+// Whether t is in the window. t must be in the monitor's time zone.
+func (w *TimeWindow) contains(t time.Time) (bool, error) {
+	if err := w.validate(nil).ToAggregate(); err != nil {
+		return false, err
+	}
+	start, _ := parseClock(w.Start)
+	end, _ := parseClock(w.End)
+	if end <= start {
+		end += 24 * 60
+	}
+
+	// A window that started the day before may not be over yet
+	for _, daysAgo := range []int{0, 1} {
+		day := t.AddDate(0, 0, -daysAgo)
+		if !w.startsOn(day.Weekday()) {
+			continue
+		}
+		// time.Date normalizes minutes past the end of the day, and handles daylight saving time
+		windowStart := time.Date(day.Year(), day.Month(), day.Day(), 0, start, 0, 0, t.Location())
+		windowEnd := time.Date(day.Year(), day.Month(), day.Day(), 0, end, 0, 0, t.Location())
+		if !t.Before(windowStart) && t.Before(windowEnd) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (w *TimeWindow) describe(field string, i int) string {
+	if w.Name != "" {
+		return fmt.Sprintf("'%s'", w.Name)
+	}
+	return fmt.Sprintf("%s[%d]", field, i)
+}
+
+// Why a run at `now` should be skipped because of `active_windows` or `blackout_windows`, or empty to run it
+func (h *HttpMonitor) SkipReason(now time.Time) (string, error) {
+	if len(h.Spec.ActiveWindows) == 0 && len(h.Spec.BlackoutWindows) == 0 {
+		return "", nil
+	}
+	loc, err := h.location()
+	if err != nil {
+		return "", err
+	}
+	now = now.In(loc)
+
+	for i := range h.Spec.BlackoutWindows {
+		window := &h.Spec.BlackoutWindows[i]
+		in, err := window.contains(now)
+		if err != nil {
+			return "", fmt.Errorf("blackout_windows[%d]: %w", i, err)
+		}
+		if in {
+			return fmt.Sprintf("in blackout window %s", window.describe("blackout_windows", i)), nil
+		}
+	}
+
+	if len(h.Spec.ActiveWindows) == 0 {
+		return "", nil
+	}
+	for i := range h.Spec.ActiveWindows {
+		in, err := h.Spec.ActiveWindows[i].contains(now)
+		if err != nil {
+			return "", fmt.Errorf("active_windows[%d]: %w", i, err)
+		}
+		if in {
+			return "", nil
+		}
+	}
+	return "outside of active_windows", nil
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHttpMonitor_SkipReason(t *testing.T) {
+	pacific, _ := time.LoadLocation("America/Los_Angeles")
+	maintenance := TimeWindow{Name: "nightly database maintenance", Start: "23:30", End: "01:00"}
+	businessHours := TimeWindow{Days: []Weekday{"mon", "tue", "wed", "thu", "fri"}, Start: "08:00", End: "18:00"}
+
+	// 2020-05-01 is a Friday
+	tests := []struct {
+		TestName        string
+		ActiveWindows   []TimeWindow
+		BlackoutWindows []TimeWindow
+		Now             time.Time
+		Expected        string
+	}{
+		{"no-windows", nil, nil, time.Date(2020, 5, 1, 12, 0, 0, 0, pacific), ""},
+		{"in-active-window", []TimeWindow{businessHours}, nil, time.Date(2020, 5, 1, 12, 0, 0, 0, pacific), ""},
+		{"active-window-start-is-included", []TimeWindow{businessHours}, nil, time.Date(2020, 5, 1, 8, 0, 0, 0, pacific), ""},
+		{"active-window-end-is-excluded", []TimeWindow{businessHours}, nil, time.Date(2020, 5, 1, 18, 0, 0, 0, pacific), "outside of active_windows"},
+		{"active-window-other-day", []TimeWindow{businessHours}, nil, time.Date(2020, 5, 2, 12, 0, 0, 0, pacific), "outside of active_windows"},
+		{"active-window-time-zone", []TimeWindow{businessHours}, nil, time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC), "outside of active_windows"},
+		{"in-blackout-window", nil, []TimeWindow{maintenance}, time.Date(2020, 5, 1, 23, 45, 0, 0, pacific), "in blackout window 'nightly database maintenance'"},
+		{"blackout-window-past-midnight", nil, []TimeWindow{maintenance}, time.Date(2020, 5, 2, 0, 30, 0, 0, pacific), "in blackout window 'nightly database maintenance'"},
+		{"outside-blackout-window", nil, []TimeWindow{maintenance}, time.Date(2020, 5, 2, 1, 0, 0, 0, pacific), ""},
+		{"unnamed-blackout-window", nil, []TimeWindow{businessHours, {Start: "12:00", End: "12:00"}}, time.Date(2020, 5, 2, 12, 0, 0, 0, pacific), "in blackout window blackout_windows[1]"},
+		{"blackout-wins", []TimeWindow{businessHours}, []TimeWindow{{Start: "12:00", End: "13:00"}}, time.Date(2020, 5, 1, 12, 0, 0, 0, pacific), "in blackout window blackout_windows[0]"},
+		// Friday's window is still going on Saturday morning
+		{"overnight-window-starts-on-the-day-before", []TimeWindow{{Days: []Weekday{"fri"}, Start: "22:00", End: "06:00"}}, nil, time.Date(2020, 5, 2, 5, 0, 0, 0, pacific), ""},
+	}
+
+	for _, testdata := range tests {
+		monitor := &HttpMonitor{Spec: HttpMonitorSpec{
+			TimeZone:        "America/Los_Angeles",
+			ActiveWindows:   testdata.ActiveWindows,
+			BlackoutWindows: testdata.BlackoutWindows,
+		}}
+		reason, err := monitor.SkipReason(testdata.Now)
+		if err != nil {
+			t.Errorf("[%s] got unexpected err: %s", testdata.TestName, err)
+			continue
+		}
+		if reason != testdata.Expected {
+			t.Errorf("[%s] Got: '%s', expected: '%s'", testdata.TestName, reason, testdata.Expected)
+		}
+	}
+
+	monitor := &HttpMonitor{Spec: HttpMonitorSpec{BlackoutWindows: []TimeWindow{{Start: "2am", End: "3am"}}}}
+	if _, err := monitor.SkipReason(time.Now()); err == nil {
+		t.Errorf("expected an invalid window to fail")
+	}
+}
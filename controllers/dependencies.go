@@ -0,0 +1,178 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+
+package controllers
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatainformer"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sync"
+)
+
+var (
+	secretsResource    = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	configMapsResource = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+)
+
+// Watches the metadata of Secrets and ConfigMaps, only in namespaces that have HttpMonitors, so referenced
+// objects can be detected to change without keeping every Secret of the cluster in memory.
+// Implements manager.Runnable, watches only start once the manager is started.
+type DependencyWatcher struct {
+	client metadata.Interface
+
+	// Events for changed Secrets and ConfigMaps, meant for a source.Channel
+	SecretEvents    chan event.GenericEvent
+	ConfigMapEvents chan event.GenericEvent
+
+	lock sync.Mutex
+	// The monitors of each namespace, by key
+	monitors map[string]map[string]bool
+	// Closed to stop the watches of a namespace
+	watches map[string]chan struct{}
+	// Closed when the manager stops. Nil until started.
+	stop <-chan struct{}
+}
+
+func NewDependencyWatcher(client metadata.Interface) *DependencyWatcher {
+	return &DependencyWatcher{
+		client:          client,
+		SecretEvents:    make(chan event.GenericEvent),
+		ConfigMapEvents: make(chan event.GenericEvent),
+		monitors:        make(map[string]map[string]bool),
+		watches:         make(map[string]chan struct{}),
+	}
+}
+
+// Start implements manager.Runnable. Starts watching the namespaces tracked so far, and any tracked later.
+func (w *DependencyWatcher) Start(stop <-chan struct{}) error {
+	w.lock.Lock()
+	w.stop = stop
+	for namespace := range w.monitors {
+		w.watchLocked(namespace)
+	}
+	w.lock.Unlock()
+
+	<-stop
+	return nil
+}
+
+// Watch the namespace of a monitor, if it isn't already
+func (w *DependencyWatcher) Track(namespace, key string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.monitors[namespace] == nil {
+		w.monitors[namespace] = make(map[string]bool)
+	}
+	w.monitors[namespace][key] = true
+	w.watchLocked(namespace)
+}
+
+// Forget a removed monitor. Stops watching its namespace once no monitors are left there.
+func (w *DependencyWatcher) Untrack(namespace, key string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	delete(w.monitors[namespace], key)
+	if len(w.monitors[namespace]) > 0 {
+		return
+	}
+	delete(w.monitors, namespace)
+	if stop, ok := w.watches[namespace]; ok {
+		close(stop)
+		delete(w.watches, namespace)
+	}
+}
+
+// Must hold the lock
+func (w *DependencyWatcher) watchLocked(namespace string) {
+	if w.stop == nil {
+		return
+	}
+	if _, ok := w.watches[namespace]; ok {
+		return
+	}
+	stop := make(chan struct{})
+	w.watches[namespace] = stop
+
+	// Stop with the manager, or once the namespace is untracked
+	managerStop := w.stop
+	go func() {
+		select {
+		case <-managerStop:
+			w.lock.Lock()
+			if w.watches[namespace] == stop {
+				close(stop)
+				delete(w.watches, namespace)
+			}
+			w.lock.Unlock()
+		case <-stop:
+		}
+	}()
+
+	w.runInformer(secretsResource, namespace, w.SecretEvents, stop)
+	w.runInformer(configMapsResource, namespace, w.ConfigMapEvents, stop)
+}
+
+func (w *DependencyWatcher) runInformer(resource schema.GroupVersionResource, namespace string, events chan<- event.GenericEvent, stop <-chan struct{}) {
+	informer := metadatainformer.NewFilteredMetadataInformer(w.client, resource, namespace, 0, cache.Indexers{}, nil).Informer()
+	send := func(obj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		meta, ok := obj.(metav1.Object)
+		if !ok {
+			return
+		}
+		runtimeObj, ok := obj.(runtime.Object)
+		if !ok {
+			return
+		}
+		select {
+		case events <- event.GenericEvent{Meta: meta, Object: runtimeObj}:
+		case <-stop:
+		}
+	}
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    send,
+		UpdateFunc: func(_, obj interface{}) { send(obj) },
+		DeleteFunc: send,
+	})
+	go informer.Run(stop)
+}
+
+// The namespaces being watched
+func (w *DependencyWatcher) watchedNamespaces() map[string]bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	namespaces := make(map[string]bool, len(w.watches))
+	for namespace := range w.watches {
+		namespaces[namespace] = true
+	}
+	return namespaces
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+
+package controllers
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/metadata/fake"
+	"testing"
+	"time"
+)
+
+func newTestSecretMetadata(namespace, name string) *metav1.PartialObjectMetadata {
+	return &metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+	}
+}
+
+func TestDependencyWatcher(t *testing.T) {
+	scheme := runtime.NewScheme()
+	metav1.AddMetaToScheme(scheme)
+	watcher := NewDependencyWatcher(fake.NewSimpleMetadataClient(scheme,
+		newTestSecretMetadata("monitored", "creds"),
+		newTestSecretMetadata("other", "creds"),
+	))
+
+	// Nothing is watched until the manager starts
+	watcher.Track("monitored", "monitored/a")
+	watcher.Track("monitored", "monitored/b")
+	if namespaces := watcher.watchedNamespaces(); len(namespaces) != 0 {
+		t.Errorf("expected no watches before start, got %v", namespaces)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() { _ = watcher.Start(stop) }()
+
+	select {
+	case e := <-watcher.SecretEvents:
+		if e.Meta.GetNamespace() != "monitored" || e.Meta.GetName() != "creds" {
+			t.Errorf("unexpected event for %s/%s", e.Meta.GetNamespace(), e.Meta.GetName())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected an event for the secret of the monitored namespace")
+	}
+	if namespaces := watcher.watchedNamespaces(); len(namespaces) != 1 || !namespaces["monitored"] {
+		t.Errorf("expected only the monitored namespace to be watched, got %v", namespaces)
+	}
+
+	// The namespace is watched until its last monitor is removed
+	watcher.Untrack("monitored", "monitored/a")
+	if namespaces := watcher.watchedNamespaces(); !namespaces["monitored"] {
+		t.Errorf("expected the namespace to be watched while a monitor is left")
+	}
+	watcher.Untrack("monitored", "monitored/b")
+	if namespaces := watcher.watchedNamespaces(); len(namespaces) != 0 {
+		t.Errorf("expected no watches once the monitors are removed, got %v", namespaces)
+	}
+}
@@ -32,13 +32,20 @@ import (
 	"github.com/oregondesignservices/monitoring-controller/internal/metrics"
 	runnverv1alpha1 "github.com/oregondesignservices/monitoring-controller/internal/runner/v1alpha1"
 	"github.com/prometheus/client_golang/prometheus"
-	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/metadata"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 	"strconv"
+	"strings"
 
 	monitoringraisingthefloororgv1alpha1 "github.com/oregondesignservices/monitoring-controller/api/v1alpha1"
 )
@@ -48,10 +55,21 @@ type HttpMonitorReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+	// The runner of each known HttpMonitor
+	Runners *runnverv1alpha1.Manager
+	// The maximum number of HttpMonitors reconciled at the same time. Defaults to 1.
+	MaxConcurrentReconciles int
+	// Reads Secrets and ConfigMaps without caching them, like the manager's API reader. Defaults to Client.
+	APIReader client.Reader
+	// Watches for changes to referenced Secrets and ConfigMaps. Set up by SetupWithManager.
+	Dependencies *DependencyWatcher
 }
 
 // +kubebuilder:rbac:groups=monitoring.raisingthefloor.org,resources=httpmonitors,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=monitoring.raisingthefloor.org,resources=httpmonitors/status,verbs=get;update;patch
+// Values are read with get. list and watch are only for the metadata of Secrets and ConfigMaps in namespaces
+// with HttpMonitors, so a Role in each of those namespaces is enough in place of this ClusterRole rule.
+// +kubebuilder:rbac:groups="",resources=secrets;configmaps,verbs=get;list;watch
 
 func (r *HttpMonitorReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	instance := &monitoringraisingthefloororgv1alpha1.HttpMonitor{}
@@ -59,17 +77,20 @@ func (r *HttpMonitorReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error)
 	logger := r.Log.WithValues("httpmonitor", req.NamespacedName, "key", req.NamespacedName.String())
 
 	runnerKey := req.NamespacedName.String()
-	knownRunner, runnerExists := runnverv1alpha1.KnownRunners[runnerKey]
+	knownRunner, runnerExists := r.Runners.Get(runnerKey)
 
 	err := r.Get(ctx, req.NamespacedName, instance)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			removeKnownHttpCrdGauge(logger, req.Namespace, req.Name)
+			if r.Dependencies != nil {
+				r.Dependencies.Untrack(req.Namespace, runnerKey)
+			}
 			// Object not found. See if we need to stop a monitor
 			if runnerExists {
 				logger.Info("removing monitor")
-				knownRunner.Stop()
-				delete(runnverv1alpha1.KnownRunners, runnerKey)
+				r.Runners.Remove(runnerKey)
+				r.logRunners()
 			}
 			return reconcile.Result{}, nil
 		}
@@ -77,7 +98,11 @@ func (r *HttpMonitorReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error)
 		return reconcile.Result{}, err
 	}
 
-	logger = logger.WithValues("period", instance.Spec.Period.Duration.String())
+	if instance.Spec.Schedule != "" {
+		logger = logger.WithValues("schedule", instance.Spec.Schedule, "time_zone", instance.Spec.TimeZone)
+	} else if instance.Spec.Period != nil {
+		logger = logger.WithValues("period", instance.Spec.Period.Duration.String())
+	}
 
 	if instance.Spec.Environment == nil {
 		instance.Spec.Environment = make(map[string]string)
@@ -91,80 +116,163 @@ func (r *HttpMonitorReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error)
 		}
 	}
 
+	if r.Dependencies != nil {
+		r.Dependencies.Track(req.Namespace, runnerKey)
+	}
+	dependencyVersion, err := r.dependencyVersion(ctx, instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
 	if !runnerExists {
 		logger.Info("detected a new http monitor")
 	} else {
-		// If the resource version is the same, we have nothing to do. We know about the exact object.
-		if instance.GetResourceVersion() == knownRunner.GetResourceVersion() {
+		// If the generation is the same, we have nothing to do. We know about the exact spec.
+		// The resource version can't be used since the runner updates the status.
+		if instance.GetGeneration() == knownRunner.GetGeneration() && dependencyVersion == knownRunner.DependencyVersion {
 			logger.V(3).Info("received a known http monitor with no changes")
 			return reconcile.Result{}, nil
 		} else {
 			logger.Info("detected http monitor changes")
-			knownRunner.Stop()
 			removeKnownHttpCrdGauge(logger, req.Namespace, req.Name)
 		}
 	}
 
 	recordKnownHttpCrdGauge(instance)
 
-	// At this point, we need to store the http monitor and restart its worker routine
-	newRunner := runnverv1alpha1.NewHttpMonitorRunner(instance)
-	runnverv1alpha1.KnownRunners[runnerKey] = newRunner
-	newRunner.Start()
+	// At this point, we need to store the http monitor and restart its worker routine.
+	// Replacing a runner stops the old one.
+	newRunner := runnverv1alpha1.NewHttpMonitorRunner(instance, r.Client, r.reader(), dependencyVersion)
+	r.Runners.Replace(runnerKey, newRunner)
+	r.logRunners()
 
 	return ctrl.Result{}, nil
 }
 
-func labelPairsToLabels(pairs []*dto.LabelPair) prometheus.Labels {
-	m := prometheus.Labels{}
+// Log all runners, to help with debugging
+func (r *HttpMonitorReconciler) logRunners() {
+	if logger := r.Log.V(1); logger.Enabled() {
+		for _, info := range r.Runners.List() {
+			logger.Info("known runner", "key", info.Key, "state", info.State, "since", info.Since,
+				"generation", info.Generation, "dependencyVersion", info.DependencyVersion)
+		}
+	}
+}
+
+// Reading Secrets and ConfigMaps with the cached client would cache every one of the cluster
+func (r *HttpMonitorReconciler) reader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+// Identifies the current versions of all Secrets and ConfigMaps referenced by the monitor,
+// so the runner can be restarted when any of them change.
+func (r *HttpMonitorReconciler) dependencyVersion(ctx context.Context, instance *monitoringraisingthefloororgv1alpha1.HttpMonitor) (string, error) {
+	var versions []string
 
-	for _, pair := range pairs {
-		m[*pair.Name] = *pair.Value
+	getVersion := func(kind, name string, obj runtime.Object) error {
+		err := r.reader().Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: name}, obj)
+		if errors.IsNotFound(err) {
+			versions = append(versions, kind+"/"+name+"=missing")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+		versions = append(versions, kind+"/"+name+"="+accessor.GetResourceVersion())
+		return nil
 	}
 
-	return m
+	for _, name := range instance.ReferencedSecrets() {
+		if err := getVersion("secret", name, &corev1.Secret{}); err != nil {
+			return "", err
+		}
+	}
+	for _, name := range instance.ReferencedConfigMaps() {
+		if err := getVersion("configmap", name, &corev1.ConfigMap{}); err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(versions, ","), nil
 }
 
-// We need to remove the existing gauge so we can update its details
-func removeKnownHttpCrdGauge(logger logr.Logger, namespace, name string) {
-	ch := make(chan prometheus.Metric)
-	go func() {
-		metrics.KnownHttpCrdGauge.Collect(ch)
-		close(ch)
-	}()
-
-	var toDelete []prometheus.Labels
-	for m := range ch {
-		pb := &dto.Metric{}
-		err := m.Write(pb)
+// Maps a Secret or ConfigMap to the monitors in the same namespace that reference it
+func (r *HttpMonitorReconciler) monitorsReferencing(referenced func(*monitoringraisingthefloororgv1alpha1.HttpMonitor) []string) handler.ToRequestsFunc {
+	return func(obj handler.MapObject) []reconcile.Request {
+		monitors := &monitoringraisingthefloororgv1alpha1.HttpMonitorList{}
+		err := r.List(context.Background(), monitors, client.InNamespace(obj.Meta.GetNamespace()))
 		if err != nil {
-			ctrl.Log.Error(err, "failed to decode metric")
+			r.Log.Error(err, "failed to list http monitors", "namespace", obj.Meta.GetNamespace())
+			return nil
 		}
-		labels := labelPairsToLabels(pb.GetLabel())
-		if labels["namespace"] == namespace && labels["name"] == name {
-			toDelete = append(toDelete, labels)
+
+		var requests []reconcile.Request
+		for i := range monitors.Items {
+			for _, name := range referenced(&monitors.Items[i]) {
+				if name == obj.Meta.GetName() {
+					requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+						Namespace: monitors.Items[i].Namespace,
+						Name:      monitors.Items[i].Name,
+					}})
+					break
+				}
+			}
 		}
+		return requests
 	}
+}
 
-	for _, labelsToDelete := range toDelete {
-		logger.Info("deleting existing metric in KnownHttpCrdGauge", "labels", labelsToDelete)
-		metrics.KnownHttpCrdGauge.Delete(labelsToDelete)
+// We need to remove the existing gauge so we can update its details
+func removeKnownHttpCrdGauge(logger logr.Logger, namespace, name string) {
+	deleted := metrics.DeleteMatching(metrics.KnownHttpCrdGauge, prometheus.Labels{
+		"namespace": namespace,
+		"name":      name,
+	})
+	for _, labels := range deleted {
+		logger.Info("deleted existing metric in KnownHttpCrdGauge", "labels", labels)
 	}
 }
 
 func recordKnownHttpCrdGauge(crd *monitoringraisingthefloororgv1alpha1.HttpMonitor) {
+	period := ""
+	if crd.Spec.Period != nil {
+		period = crd.Spec.Period.Duration.String()
+	}
 	metrics.KnownHttpCrdGauge.With(prometheus.Labels{
 		"namespace":            crd.Namespace,
 		"name":                 crd.Name,
 		"num_requests":         strconv.Itoa(len(crd.Spec.Requests)),
 		"num_cleanup_requests": strconv.Itoa(len(crd.Spec.Cleanup)),
-		"period":               crd.Spec.Period.Duration.String(),
+		"period":               period,
+		"schedule":             crd.Spec.Schedule,
 		"num_globals":          strconv.Itoa(len(crd.Spec.Environment)),
 	}).Set(1)
 }
 
 func (r *HttpMonitorReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	metadataClient, err := metadata.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+	r.Dependencies = NewDependencyWatcher(metadataClient)
+	if err := mgr.Add(r.Dependencies); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&monitoringraisingthefloororgv1alpha1.HttpMonitor{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Watches(&source.Channel{Source: r.Dependencies.SecretEvents}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: r.monitorsReferencing((*monitoringraisingthefloororgv1alpha1.HttpMonitor).ReferencedSecrets),
+		}).
+		Watches(&source.Channel{Source: r.Dependencies.ConfigMapEvents}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: r.monitorsReferencing((*monitoringraisingthefloororgv1alpha1.HttpMonitor).ReferencedConfigMaps),
+		}).
 		Complete(r)
 }
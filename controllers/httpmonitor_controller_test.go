@@ -0,0 +1,138 @@
+/*
+Copyright 2020 Raising the Floor - International
+
+Licensed under the New BSD license. You may not use this file except in
+compliance with this License.
+
+You may obtain a copy of the License at
+https://github.com/GPII/universal/blob/master/LICENSE.txt
+
+The R&D leading to these results received funding from the:
+* Rehabilitation Services Administration, US Dept. of Education under
+  grant H421A150006 (APCP)
+* National Institute on Disability, Independent Living, and
+  Rehabilitation Research (NIDILRR)
+* Administration for Independent Living & Dept. of Education under grants
+  H133E080022 (RERC-IT) and H133E130028/90RE5003-01-00 (UIITA-RERC)
+* European Union's Seventh Framework Programme (FP7/2007-2013) grant
+  agreement nos. 289016 (Cloud4all) and 610510 (Prosperity4All)
+* William and Flora Hewlett Foundation
+* Ontario Ministry of Research and Innovation
+* Canadian Foundation for Innovation
+* Adobe Foundation
+* Consumer Electronics Association Foundation
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	runnverv1alpha1 "github.com/oregondesignservices/monitoring-controller/internal/runner/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sync"
+	"testing"
+	"time"
+
+	monitoringraisingthefloororgv1alpha1 "github.com/oregondesignservices/monitoring-controller/api/v1alpha1"
+)
+
+func newTestReconciler(objs ...runtime.Object) *HttpMonitorReconciler {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = monitoringraisingthefloororgv1alpha1.AddToScheme(scheme)
+
+	return &HttpMonitorReconciler{
+		Client:  fake.NewFakeClientWithScheme(scheme, objs...),
+		Log:     ctrl.Log.WithName("test"),
+		Scheme:  scheme,
+		Runners: runnverv1alpha1.NewManager(),
+	}
+}
+
+func newTestMonitor(name string, generation int64) *monitoringraisingthefloororgv1alpha1.HttpMonitor {
+	return &monitoringraisingthefloororgv1alpha1.HttpMonitor{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  "monitoring",
+			Name:       name,
+			Generation: generation,
+		},
+		Spec: monitoringraisingthefloororgv1alpha1.HttpMonitorSpec{
+			Period: &metav1.Duration{Duration: time.Hour},
+		},
+	}
+}
+
+// Reconcile every request at the same time, several times each
+func reconcileConcurrently(t *testing.T, r *HttpMonitorReconciler, requests []ctrl.Request) {
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		for _, req := range requests {
+			wg.Add(1)
+			go func(req ctrl.Request) {
+				defer wg.Done()
+				if _, err := r.Reconcile(req); err != nil {
+					t.Errorf("[%s] got unexpected err: %s", req.Name, err)
+				}
+			}(req)
+		}
+	}
+	wg.Wait()
+}
+
+func TestHttpMonitorReconciler_ReconcileConcurrently(t *testing.T) {
+	var objs []runtime.Object
+	var requests []ctrl.Request
+	for i := 0; i < 10; i++ {
+		monitor := newTestMonitor(fmt.Sprintf("monitor-%d", i), 1)
+		objs = append(objs, monitor)
+		requests = append(requests, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: monitor.Namespace, Name: monitor.Name}})
+	}
+	r := newTestReconciler(objs...)
+
+	reconcileConcurrently(t, r, requests)
+	if active := len(r.Runners.List()); active < len(requests) {
+		t.Errorf("expected a runner for every monitor, got: %d", active)
+	}
+
+	// Change half of the monitors and delete the others
+	ctx := context.Background()
+	for i, req := range requests {
+		monitor := &monitoringraisingthefloororgv1alpha1.HttpMonitor{}
+		if err := r.Get(ctx, req.NamespacedName, monitor); err != nil {
+			t.Fatalf("got unexpected err: %s", err)
+		}
+		if i%2 == 0 {
+			monitor.Generation = 2
+			err := r.Update(ctx, monitor)
+			if err != nil {
+				t.Fatalf("got unexpected err: %s", err)
+			}
+		} else if err := r.Delete(ctx, monitor); err != nil {
+			t.Fatalf("got unexpected err: %s", err)
+		}
+	}
+	reconcileConcurrently(t, r, requests)
+
+	for i, req := range requests {
+		runner, ok := r.Runners.Get(req.NamespacedName.String())
+		if i%2 != 0 {
+			if ok {
+				t.Errorf("[%s] expected the runner of a deleted monitor to be removed", req.Name)
+			}
+			continue
+		}
+		if !ok || runner.GetGeneration() != 2 {
+			t.Errorf("[%s] expected a runner for generation 2", req.Name)
+		}
+	}
+
+	for _, req := range requests {
+		r.Runners.Remove(req.NamespacedName.String())
+	}
+}
@@ -3,16 +3,25 @@ module github.com/oregondesignservices/monitoring-controller
 go 1.13
 
 require (
+	github.com/andybalholm/cascadia v1.1.0
+	github.com/antchfx/htmlquery v1.2.3
+	github.com/antchfx/xmlquery v1.2.4
+	github.com/antchfx/xpath v1.1.10
 	github.com/ghodss/yaml v1.0.0
 	github.com/go-logr/logr v0.1.0
-	github.com/json-iterator/go v1.1.8
+	github.com/google/uuid v1.1.1
+	github.com/jmespath/go-jmespath v0.4.0
+	github.com/json-iterator/go v1.1.12
 	github.com/onsi/ginkgo v1.11.0
 	github.com/onsi/gomega v1.8.1
 	github.com/prometheus/client_golang v1.0.0
 	github.com/prometheus/client_model v0.0.0-20190129233127-fd36f4220a90
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/urfave/cli/v2 v2.2.0
 	go.uber.org/zap v1.10.0
+	golang.org/x/oauth2 v0.0.0-20190604053449-0f29369cfe45
 	k8s.io/api v0.17.2
+	k8s.io/apiextensions-apiserver v0.17.2
 	k8s.io/apimachinery v0.17.2
 	k8s.io/client-go v0.17.2
 	sigs.k8s.io/controller-runtime v0.5.0
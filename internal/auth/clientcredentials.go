@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Keeps OAuth2 tokens between runs of a monitor, so they are reused until they expire. Each runner has its own,
+// so tokens are dropped with the runner when its monitor changes or is removed.
+type TokenCache struct {
+	lock   sync.Mutex
+	tokens map[string]*cachedToken
+}
+
+type cachedToken struct {
+	// Held while fetching, so concurrent requests wait for one token instead of each fetching their own
+	lock sync.Mutex
+	// Written while holding both locks, so it can be read with either
+	token *oauth2.Token
+}
+
+func NewTokenCache() *TokenCache {
+	return &TokenCache{tokens: make(map[string]*cachedToken)}
+}
+
+// The cached token for a key. Expired tokens for other keys are dropped, for example after a secret is rotated.
+func (c *TokenCache) entry(key string) *cachedToken {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for other, cached := range c.tokens {
+		if other != key && cached.token != nil && !cached.token.Valid() {
+			delete(c.tokens, other)
+		}
+	}
+	cached, ok := c.tokens[key]
+	if !ok {
+		cached = &cachedToken{}
+		c.tokens[key] = cached
+	}
+	return cached
+}
+
+func cacheKey(config *clientcredentials.Config) string {
+	params := make([]string, 0, len(config.EndpointParams))
+	for key, values := range config.EndpointParams {
+		params = append(params, key+"="+strings.Join(values, ","))
+	}
+	sort.Strings(params)
+
+	hash := sha256.New()
+	for _, piece := range []string{
+		config.TokenURL,
+		config.ClientID,
+		config.ClientSecret,
+		strings.Join(config.Scopes, " "),
+		strings.Join(params, "&"),
+	} {
+		hash.Write([]byte(piece))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Get a token using the OAuth2 client credentials flow. Tokens are cached by configuration, including
+// the client secret, so rotating the secret results in a new token. Fetching a token stops when ctx is done.
+func (c *TokenCache) ClientCredentialsToken(ctx context.Context, httpClient *http.Client, tokenUrl, clientId, clientSecret string, scopes []string, params url.Values) (*oauth2.Token, error) {
+	config := &clientcredentials.Config{
+		ClientID:       clientId,
+		ClientSecret:   clientSecret,
+		TokenURL:       tokenUrl,
+		Scopes:         scopes,
+		EndpointParams: params,
+	}
+	key := cacheKey(config)
+
+	cached := c.entry(key)
+	cached.lock.Lock()
+	defer cached.lock.Unlock()
+	if cached.token.Valid() {
+		return cached.token, nil
+	}
+	// Unlike a TokenSource, which keeps the context it was created with, each refresh uses the current run's
+	token, err := config.Token(context.WithValue(ctx, oauth2.HTTPClient, httpClient))
+	if err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	cached.token = token
+	c.lock.Unlock()
+	return token, nil
+}
@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTokenCache_ClientCredentialsToken(t *testing.T) {
+	tokenRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		w.Header().Set("Content-Type", "application/json")
+		// Expires right away, so every call refreshes
+		_, _ = w.Write([]byte(`{"access_token": "token", "token_type": "Bearer", "expires_in": 1}`))
+	}))
+	defer server.Close()
+
+	cache := NewTokenCache()
+	for _, secret := range []string{"old", "old", "rotated"} {
+		if _, err := cache.ClientCredentialsToken(context.Background(), server.Client(), server.URL, "id", secret, nil, nil); err != nil {
+			t.Fatalf("got unexpected err: %s", err)
+		}
+	}
+
+	if tokenRequests != 3 {
+		t.Errorf("expected expired tokens to be refreshed, got %d token requests", tokenRequests)
+	}
+	if len(cache.tokens) != 1 {
+		t.Errorf("expected the expired token of the old secret to be dropped, got %d tokens", len(cache.tokens))
+	}
+}
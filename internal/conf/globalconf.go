@@ -5,6 +5,7 @@ import (
 	"github.com/oregondesignservices/monitoring-controller/internal/httpclient"
 	"github.com/oregondesignservices/monitoring-controller/internal/metrics"
 	"github.com/urfave/cli/v2"
+	"os"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"strings"
@@ -28,6 +29,42 @@ var (
 			Value: 29 * time.Second,
 			Usage: "the http client timeout duration",
 		},
+		&cli.Float64SliceFlag{
+			Name:  "latency-buckets",
+			Usage: "histogram buckets, in seconds, for request latency metrics. Default is the prometheus default buckets",
+		},
+		&cli.DurationFlag{
+			Name:  "status-update-interval",
+			Value: 30 * time.Second,
+			Usage: "the minimum time between HttpMonitor status updates. Changes in health are always written",
+		},
+		&cli.BoolFlag{
+			Name:  "run-immediately",
+			Usage: "execute monitors as soon as they are created or changed, instead of after the first period. Can be set per monitor",
+		},
+		&cli.DurationFlag{
+			Name:  "initial-jitter",
+			Usage: "delay the first run of each monitor by a random duration up to this. Can be set per monitor",
+		},
+		&cli.DurationFlag{
+			Name:  "shutdown-grace-period",
+			Value: 30 * time.Second,
+			Usage: "on shutdown, how long runs in progress may take before they are cancelled. Cleanup requests run either way",
+		},
+		&cli.DurationFlag{
+			Name:  "cleanup-timeout",
+			Value: 15 * time.Second,
+			Usage: "how long the cleanup requests of a run may take, including retries. Together with --shutdown-grace-period, limits how long shutdown takes",
+		},
+		&cli.IntFlag{
+			Name:  "max-concurrent-reconciles",
+			Value: 1,
+			Usage: "the maximum number of HttpMonitors reconciled at the same time",
+		},
+		&cli.BoolFlag{
+			Name:  "enable-webhooks",
+			Usage: "serve the HttpMonitor validating webhook. Requires serving certificates, see config/default",
+		},
 		&cli.BoolFlag{
 			Name:  "enable-leader-election",
 			Usage: "Enable leader election for controller manager",
@@ -36,6 +73,20 @@ var (
 			Name:  "set-var",
 			Usage: "set a global variable available to all requests. Format: 'key=value'",
 		},
+		&cli.StringSliceFlag{
+			Name:  "set-secret-var",
+			Usage: "same as --set-var, but the value is redacted from logs, metrics and status. Format: 'key=value'",
+		},
+		&cli.StringFlag{
+			Name:    "pod-name",
+			EnvVars: []string{"POD_NAME"},
+			Usage:   "the name of the controller pod, available as the pod-name variable. Defaults to the hostname",
+		},
+		&cli.StringFlag{
+			Name:  "random-email-domain",
+			Value: "example.com",
+			Usage: "the domain of the random-email variable",
+		},
 		&cli.BoolFlag{
 			Name:  "verbose",
 			Usage: "enable verbose output",
@@ -44,20 +95,48 @@ var (
 )
 
 type configuration struct {
-	MetricsAddr          string
-	Namespace            string
-	HttpClientTimeout    time.Duration
-	EnableLeaderElection bool
-	GlobalRequestVars    map[string]string
+	MetricsAddr             string
+	Namespace               string
+	HttpClientTimeout       time.Duration
+	LatencyBuckets          []float64
+	StatusUpdateInterval    time.Duration
+	RunImmediately          bool
+	InitialJitter           time.Duration
+	ShutdownGracePeriod     time.Duration
+	CleanupTimeout          time.Duration
+	MaxConcurrentReconciles int
+	EnableLeaderElection    bool
+	EnableWebhooks          bool
+	PodName                 string
+	RandomEmailDomain       string
+	GlobalRequestVars       map[string]string
+	// Not merged into HttpMonitor environments, so they can be redacted
+	GlobalSecretRequestVars map[string]string
 }
 
 func (c *configuration) UpdateFromCli(ctx *cli.Context) error {
 	c.MetricsAddr = ctx.String("metrics-addr")
 	c.Namespace = ctx.String("namespace")
 	c.HttpClientTimeout = ctx.Duration("http-client-timeout")
+	c.LatencyBuckets = ctx.Float64Slice("latency-buckets")
+	c.StatusUpdateInterval = ctx.Duration("status-update-interval")
+	c.RunImmediately = ctx.Bool("run-immediately")
+	c.InitialJitter = ctx.Duration("initial-jitter")
+	c.ShutdownGracePeriod = ctx.Duration("shutdown-grace-period")
+	c.CleanupTimeout = ctx.Duration("cleanup-timeout")
+	c.MaxConcurrentReconciles = ctx.Int("max-concurrent-reconciles")
 	c.EnableLeaderElection = ctx.Bool("enable-leader-election")
+	c.EnableWebhooks = ctx.Bool("enable-webhooks")
+	c.RandomEmailDomain = ctx.String("random-email-domain")
+	c.PodName = ctx.String("pod-name")
+	if c.PodName == "" {
+		c.PodName, _ = os.Hostname()
+	}
 
 	httpclient.Initialize(c.HttpClientTimeout)
+	if len(c.LatencyBuckets) > 0 {
+		metrics.SetLatencyBuckets(c.LatencyBuckets)
+	}
 	ctrl.SetLogger(zap.New(zap.UseDevMode(false)))
 
 	logger := ctrl.Log.WithName("configuration").WithName("UpdateFromCli")
@@ -71,11 +150,23 @@ func (c *configuration) UpdateFromCli(ctx *cli.Context) error {
 		}
 		c.GlobalRequestVars[pieces[0]] = pieces[1]
 		logger.Info("added global request variable", "key", pieces[0], "value", pieces[1])
-		metrics.GlobalVarsDetails.WithLabelValues(pieces...).Inc()
+		metrics.GlobalVarsDetails.WithLabelValues(pieces[0]).Inc()
+	}
+
+	for _, v := range ctx.StringSlice("set-secret-var") {
+		pieces := strings.SplitN(v, "=", 2)
+		if len(pieces) != 2 {
+			return errors.New("--set-secret-var format must be 'key=value'")
+		}
+		c.GlobalSecretRequestVars[pieces[0]] = pieces[1]
+		logger.Info("added global secret request variable", "key", pieces[0])
+		metrics.GlobalVarsDetails.WithLabelValues(pieces[0]).Inc()
 	}
 	return nil
 }
 
 var GlobalConfig = &configuration{
-	GlobalRequestVars: make(map[string]string),
+	RandomEmailDomain:       "example.com",
+	GlobalRequestVars:       make(map[string]string),
+	GlobalSecretRequestVars: make(map[string]string),
 }
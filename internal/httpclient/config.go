@@ -0,0 +1,170 @@
+package httpclient
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Per-monitor client settings. Kept as plain data so it can be hashed to find a cached client.
+type Config struct {
+	// PEM encoded CA bundle, added to the system roots
+	CACert []byte
+	// PEM encoded client certificate and key
+	ClientCert []byte
+	ClientKey  []byte
+
+	ServerName         string
+	MinVersion         uint16
+	InsecureSkipVerify bool
+
+	// Optional HTTP proxy. Defaults to the proxy from the environment.
+	ProxyUrl string
+	// Return redirect responses instead of following them
+	DisableRedirects bool
+	// Maximum number of redirects to follow. Defaults to 10.
+	MaxRedirects int
+	// Use a fresh connection for every request
+	DisableKeepAlives bool
+	// Force a protocol, "http1" or "http2". Empty uses the go default.
+	Protocol string
+	// Sent with every request that does not set its own User-Agent
+	UserAgent string
+}
+
+const (
+	ProtocolHttp1 = "http1"
+	ProtocolHttp2 = "http2"
+
+	defaultMaxRedirects = 10
+)
+
+func (c *Config) hash() (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (c *Config) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         c.ServerName,
+		MinVersion:         c.MinVersion,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	if len(c.CACert) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(c.CACert) {
+			return nil, errors.New("no valid certificates found in CA bundle")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(c.ClientCert) > 0 || len(c.ClientKey) > 0 {
+		cert, err := tls.X509KeyPair(c.ClientCert, c.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+func (c *Config) checkRedirect(req *http.Request, via []*http.Request) error {
+	if c.DisableRedirects {
+		return http.ErrUseLastResponse
+	}
+	maxRedirects := c.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = defaultMaxRedirects
+	}
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	return nil
+}
+
+func newClient(config *Config) (*http.Client, error) {
+	tlsConfig, err := config.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.DisableKeepAlives = config.DisableKeepAlives
+
+	if config.ProxyUrl != "" {
+		proxyUrl, err := url.Parse(config.ProxyUrl)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxyUrl)
+	}
+
+	switch config.Protocol {
+	case "":
+	case ProtocolHttp1:
+		// A non-nil, empty map disables HTTP/2
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	case ProtocolHttp2:
+		transport.ForceAttemptHTTP2 = true
+	default:
+		return nil, fmt.Errorf("not a known protocol: %s", config.Protocol)
+	}
+
+	return &http.Client{
+		Timeout: clientTimeout,
+		Transport: &roundTripper{
+			base:      transport,
+			userAgent: config.UserAgent,
+			protocol:  config.Protocol,
+		},
+		CheckRedirect: config.checkRedirect,
+	}, nil
+}
+
+// Adds the User-Agent and verifies the negotiated protocol
+type roundTripper struct {
+	base      http.RoundTripper
+	userAgent string
+	protocol  string
+}
+
+func (r *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.userAgent != "" && req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", r.userAgent)
+	}
+	resp, err := r.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.protocol == ProtocolHttp2 && resp.ProtoMajor != 2 {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("expected HTTP/2, got %s", resp.Proto)
+	}
+	return resp, nil
+}
+
+// Lets http.Client.CloseIdleConnections reach the transport
+func (r *roundTripper) CloseIdleConnections() {
+	type closeIdler interface {
+		CloseIdleConnections()
+	}
+	if base, ok := r.base.(closeIdler); ok {
+		base.CloseIdleConnections()
+	}
+}
@@ -2,12 +2,32 @@ package httpclient
 
 import (
 	"net/http"
+	"sync"
 	"time"
 )
 
 var httpClient *http.Client
 
+var (
+	clientTimeout time.Duration
+	clients       = make(map[string]*cachedClient)
+	clientsLock   sync.Mutex
+)
+
+type cachedClient struct {
+	client *http.Client
+	// The number of Refs using the client
+	refs int
+}
+
+// The cached client an owner, such as a monitor's runner, currently uses. A cached client is closed and
+// dropped once no Ref uses it, like after its monitor is removed or its settings change.
+type Ref struct {
+	key string
+}
+
 func Initialize(timeout time.Duration) {
+	clientTimeout = timeout
 	httpClient = &http.Client{
 		Timeout: timeout,
 	}
@@ -16,3 +36,53 @@ func Initialize(timeout time.Duration) {
 func GetClient() *http.Client {
 	return httpClient
 }
+
+// Get a client with its own transport for the config. Clients are cached by a hash of the config,
+// so monitors with the same settings share connections. The client ref used before is released.
+func GetClientFor(ref *Ref, config Config) (*http.Client, error) {
+	key, err := config.hash()
+	if err != nil {
+		return nil, err
+	}
+
+	clientsLock.Lock()
+	defer clientsLock.Unlock()
+
+	if ref.key == key {
+		return clients[key].client, nil
+	}
+	cached, ok := clients[key]
+	if !ok {
+		client, err := newClient(&config)
+		if err != nil {
+			return nil, err
+		}
+		cached = &cachedClient{client: client}
+		clients[key] = cached
+	}
+	cached.refs++
+	releaseLocked(ref)
+	ref.key = key
+	return cached.client, nil
+}
+
+// Stop using the client of ref, if any
+func Release(ref *Ref) {
+	clientsLock.Lock()
+	defer clientsLock.Unlock()
+	releaseLocked(ref)
+}
+
+// Must hold clientsLock
+func releaseLocked(ref *Ref) {
+	if ref.key == "" {
+		return
+	}
+	cached := clients[ref.key]
+	cached.refs--
+	if cached.refs == 0 {
+		delete(clients, ref.key)
+		cached.client.CloseIdleConnections()
+	}
+	ref.key = ""
+}
@@ -0,0 +1,42 @@
+package httpclient
+
+import (
+	"testing"
+)
+
+func TestGetClientFor(t *testing.T) {
+	first, second := &Ref{}, &Ref{}
+
+	client, err := GetClientFor(first, Config{UserAgent: "shared"})
+	if err != nil {
+		t.Fatalf("got unexpected err: %s", err)
+	}
+	shared, err := GetClientFor(second, Config{UserAgent: "shared"})
+	if err != nil {
+		t.Fatalf("got unexpected err: %s", err)
+	}
+	if client != shared {
+		t.Errorf("expected the same config to share a client")
+	}
+	again, _ := GetClientFor(first, Config{UserAgent: "shared"})
+	if again != client || clients[first.key].refs != 2 {
+		t.Errorf("expected a ref to only count once")
+	}
+
+	// The first owner's settings changed
+	_, err = GetClientFor(first, Config{UserAgent: "changed"})
+	if err != nil {
+		t.Fatalf("got unexpected err: %s", err)
+	}
+	if len(clients) != 2 {
+		t.Errorf("expected the shared client to be kept while in use, got %d clients", len(clients))
+	}
+
+	Release(first)
+	Release(second)
+	// Releasing twice is a no-op
+	Release(second)
+	if len(clients) != 0 {
+		t.Errorf("expected unused clients to be dropped, got %d clients", len(clients))
+	}
+}
@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
@@ -16,21 +17,122 @@ var (
 		Help: "response status totals for each request in a CRD",
 	}, []string{"type", "crd", "requestName", "status"})
 
+	CrdHttpAssertionFailureCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "monitor_crd_http_assertion_failures_total",
+		Help: "failed response assertions for each request in a CRD",
+	}, []string{"type", "crd", "requestName", "assertion"})
+
+	CrdHttpAttemptCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "monitor_crd_http_attempts_total",
+		Help: "every attempt of each request in a CRD, including retries. Attempt is 'first' or 'retry', result is 'success' or 'failure'",
+	}, []string{"type", "crd", "requestName", "attempt", "result"})
+
+	CrdHttpRequestFailureCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "monitor_crd_http_request_failures_total",
+		Help: "requests in a CRD that failed after all attempts",
+	}, []string{"type", "crd", "requestName"})
+
+	CrdRunCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "monitor_crd_runs_total",
+		Help: "runs of each CRD. Result is 'succeeded', 'failed' or 'skipped'",
+	}, []string{"type", "crd", "result"})
+
+	CrdHttpRequestDuration      = newCrdHttpRequestDuration(prometheus.DefBuckets)
+	CrdHttpRequestPhaseDuration = newCrdHttpRequestPhaseDuration(prometheus.DefBuckets)
+
+	TLSCertExpiryGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "monitor_tls_cert_expiry_seconds",
+		Help: "seconds until each certificate presented by the server expires, as of the latest request. Position is 'leaf' or 'chain_N'",
+	}, []string{"type", "crd", "requestName", "position"})
+
 	KnownHttpCrdGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "monitor_http_crd_details",
 		Help: "details for HttpMonitor CRDs",
-	}, []string{"namespace", "name", "num_requests", "num_cleanup_requests", "period", "num_globals"})
+	}, []string{"namespace", "name", "num_requests", "num_cleanup_requests", "period", "schedule", "num_globals"})
 
 	GlobalVarsDetails = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "monitor_global_var_details",
-		Help: "information about globally accessible variables",
-	}, []string{"key", "value"})
+		Help: "information about globally accessible variables. Values are not exported",
+	}, []string{"key"})
 )
 
 func init() {
 	metrics.Registry.MustRegister(
 		HttpResponseCounter,
 		CrdHttpResponseCounter,
+		CrdHttpAssertionFailureCounter,
+		CrdHttpAttemptCounter,
+		CrdHttpRequestFailureCounter,
+		CrdRunCounter,
+		CrdHttpRequestDuration,
+		CrdHttpRequestPhaseDuration,
+		TLSCertExpiryGauge,
 		KnownHttpCrdGauge,
 		GlobalVarsDetails)
 }
+
+func newCrdHttpRequestDuration(buckets []float64) *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "monitor_crd_http_request_duration_seconds",
+		Help:    "total request duration for each request in a CRD, including reading the body",
+		Buckets: buckets,
+	}, []string{"type", "crd", "requestName", "status"})
+}
+
+func newCrdHttpRequestPhaseDuration(buckets []float64) *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "monitor_crd_http_request_phase_duration_seconds",
+		Help:    "duration of each phase (dns, connect, tls_handshake, first_byte, body_transfer) for each request in a CRD",
+		Buckets: buckets,
+	}, []string{"type", "crd", "requestName", "status", "phase"})
+}
+
+// Replace the latency histograms with ones using the given buckets.
+// Must be called before any requests are executed.
+func SetLatencyBuckets(buckets []float64) {
+	metrics.Registry.Unregister(CrdHttpRequestDuration)
+	metrics.Registry.Unregister(CrdHttpRequestPhaseDuration)
+
+	CrdHttpRequestDuration = newCrdHttpRequestDuration(buckets)
+	CrdHttpRequestPhaseDuration = newCrdHttpRequestPhaseDuration(buckets)
+
+	metrics.Registry.MustRegister(
+		CrdHttpRequestDuration,
+		CrdHttpRequestPhaseDuration)
+}
+
+// Delete every series of the gauge whose labels include all of the given labels. Returns the deleted labels.
+// Collects the whole gauge, so keep it for gauges with few series.
+func DeleteMatching(gauge *prometheus.GaugeVec, match prometheus.Labels) []prometheus.Labels {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		gauge.Collect(ch)
+		close(ch)
+	}()
+
+	var toDelete []prometheus.Labels
+	for m := range ch {
+		pb := &dto.Metric{}
+		if err := m.Write(pb); err != nil {
+			continue
+		}
+		labels := prometheus.Labels{}
+		for _, pair := range pb.GetLabel() {
+			labels[pair.GetName()] = pair.GetValue()
+		}
+		matches := true
+		for name, value := range match {
+			if labels[name] != value {
+				matches = false
+			}
+		}
+		if matches {
+			toDelete = append(toDelete, labels)
+		}
+	}
+
+	for _, labels := range toDelete {
+		gauge.Delete(labels)
+	}
+	return toDelete
+}
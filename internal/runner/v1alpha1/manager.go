@@ -0,0 +1,167 @@
+package v1alpha1
+
+import (
+	monitoringraisingthefloororgv1alpha1 "github.com/oregondesignservices/monitoring-controller/api/v1alpha1"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Describes a runner, for debugging
+type RunnerInfo struct {
+	Key               string
+	State             State
+	Since             time.Time
+	Generation        int64
+	DependencyVersion string
+}
+
+// Keeps track of the runner of each HttpMonitor. Safe for concurrent reconciles.
+// Runners only start once the Manager is started as a manager.Runnable, and stop with it.
+type Manager struct {
+	lock    sync.Mutex
+	runners map[string]*HttpMonitorRunner
+	// Stopped runners, until their current run completes
+	stopping map[*HttpMonitorRunner]string
+	// Closed when the controller manager stops. Nil until started.
+	stop <-chan struct{}
+}
+
+func NewManager() *Manager {
+	return &Manager{
+		runners:  make(map[string]*HttpMonitorRunner),
+		stopping: make(map[*HttpMonitorRunner]string),
+	}
+}
+
+// Start implements manager.Runnable. Starts all runners, and any runners added later, until stop is closed.
+// The controller manager does not wait for runnables to stop, see Wait.
+func (m *Manager) Start(stop <-chan struct{}) error {
+	m.lock.Lock()
+	m.stop = stop
+	for _, runner := range m.runners {
+		m.startLocked(runner)
+	}
+	m.lock.Unlock()
+
+	<-stop
+	return nil
+}
+
+// Wait for all runners to finish after the Manager is stopped, including their cleanup requests.
+// Returns right away if the Manager was never started. Returns false if runners are still running after timeout.
+func (m *Manager) Wait(timeout time.Duration) bool {
+	m.lock.Lock()
+	var done []<-chan struct{}
+	if m.stop != nil {
+		for _, runner := range m.runners {
+			done = append(done, runner.Done())
+		}
+		for runner := range m.stopping {
+			done = append(done, runner.Done())
+		}
+	}
+	m.lock.Unlock()
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for _, d := range done {
+		select {
+		case <-d:
+		case <-deadline.C:
+			return false
+		}
+	}
+	return true
+}
+
+// Must hold the lock
+func (m *Manager) startLocked(runner *HttpMonitorRunner) {
+	if m.stop == nil {
+		return
+	}
+	select {
+	case <-m.stop:
+		// Shutting down, so don't start anything new
+		runner.Stop()
+		return
+	default:
+	}
+	go func() {
+		if err := runner.Start(m.stop); err != nil {
+			runnerLogger.Error(err, "failed to start runner", "namespace", runner.Namespace, "name", runner.Name)
+		}
+	}()
+}
+
+// The active runner for a key, if any
+func (m *Manager) Get(key string) (*HttpMonitorRunner, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	runner, ok := m.runners[key]
+	return runner, ok
+}
+
+// Start a runner for a key, stopping the runner it replaces. Does not wait for the old runner to finish.
+// If the Manager is not started yet, the runner starts with it.
+func (m *Manager) Replace(key string, runner *HttpMonitorRunner) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if existing, ok := m.runners[key]; ok {
+		m.stopLocked(key, existing)
+		// The new runner records its own, and requests may have been renamed
+		monitoringraisingthefloororgv1alpha1.RemoveMetrics(existing.Namespace, existing.Name)
+	}
+	m.runners[key] = runner
+	m.startLocked(runner)
+}
+
+// Stop and forget the runner for a key. Returns false if there was none.
+func (m *Manager) Remove(key string) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	existing, ok := m.runners[key]
+	if !ok {
+		return false
+	}
+	m.stopLocked(key, existing)
+	return true
+}
+
+// Must hold the lock
+func (m *Manager) stopLocked(key string, runner *HttpMonitorRunner) {
+	delete(m.runners, key)
+	runner.Stop()
+	m.stopping[runner] = key
+	go func() {
+		<-runner.Done()
+		m.lock.Lock()
+		delete(m.stopping, runner)
+		// Once its last run is done, so the run can't record them again
+		if _, replaced := m.runners[key]; !replaced {
+			monitoringraisingthefloororgv1alpha1.RemoveMetrics(runner.Namespace, runner.Name)
+		}
+		m.lock.Unlock()
+	}()
+}
+
+// All active runners, and stopped runners that are still finishing a run, sorted by key
+func (m *Manager) List() []RunnerInfo {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	infos := make([]RunnerInfo, 0, len(m.runners)+len(m.stopping))
+	for key, runner := range m.runners {
+		infos = append(infos, runner.info(key))
+	}
+	for runner, key := range m.stopping {
+		infos = append(infos, runner.info(key))
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Key != infos[j].Key {
+			return infos[i].Key < infos[j].Key
+		}
+		return infos[i].Since.Before(infos[j].Since)
+	})
+	return infos
+}
@@ -0,0 +1,161 @@
+package v1alpha1
+
+import (
+	"fmt"
+	monitoringraisingthefloororgv1alpha1 "github.com/oregondesignservices/monitoring-controller/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestRunner(generation int64) *HttpMonitorRunner {
+	monitor := &monitoringraisingthefloororgv1alpha1.HttpMonitor{
+		Spec: monitoringraisingthefloororgv1alpha1.HttpMonitorSpec{
+			Period: &metav1.Duration{Duration: time.Hour},
+		},
+	}
+	monitor.Generation = generation
+	return NewHttpMonitorRunner(monitor, nil, nil, "")
+}
+
+func waitForDone(t *testing.T, runner *HttpMonitorRunner) {
+	select {
+	case <-runner.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("runner did not stop")
+	}
+}
+
+func TestHttpMonitorRunner_Stop(t *testing.T) {
+	runner := newTestRunner(1)
+	if runner.State() != StateCreated {
+		t.Errorf("unexpected state: %s", runner.State())
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() { _ = runner.Start(stop) }()
+
+	// Stop must not block and can be called more than once, concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runner.Stop()
+		}()
+	}
+	wg.Wait()
+	waitForDone(t, runner)
+	if runner.State() != StateStopped {
+		t.Errorf("unexpected state: %s", runner.State())
+	}
+
+	// A runner that never started is done right away
+	unstarted := newTestRunner(1)
+	unstarted.Stop()
+	waitForDone(t, unstarted)
+	// Stop may win the race against the manager starting the runner
+	if err := unstarted.Start(make(chan struct{})); err != nil {
+		t.Errorf("unexpected error starting a stopped runner: %s", err)
+	}
+}
+
+func TestManager(t *testing.T) {
+	manager := NewManager()
+	stop := make(chan struct{})
+	manager.Replace("ns/before-start", newTestRunner(1))
+	go func() { _ = manager.Start(stop) }()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("ns/monitor-%d", i%5)
+			manager.Replace(key, newTestRunner(int64(i)))
+			manager.Get(key)
+			manager.List()
+			if i%4 == 0 {
+				manager.Remove(key)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	manager.Remove("ns/before-start")
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("ns/monitor-%d", i)
+		manager.Replace(key, newTestRunner(100))
+	}
+	replaced, _ := manager.Get("ns/monitor-0")
+	manager.Replace("ns/monitor-0", newTestRunner(101))
+	waitForDone(t, replaced)
+	if !manager.Remove("ns/monitor-1") {
+		t.Errorf("expected ns/monitor-1 to be removed")
+	}
+	if manager.Remove("ns/monitor-1") {
+		t.Errorf("expected a second remove to do nothing")
+	}
+
+	// Stopped runners disappear from the list once they are done
+	deadline := time.Now().Add(5 * time.Second)
+	for len(manager.List()) != 4 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	infos := manager.List()
+	if len(infos) != 4 {
+		t.Fatalf("unexpected runners: %+v", infos)
+	}
+	expectedKeys := []string{"ns/monitor-0", "ns/monitor-2", "ns/monitor-3", "ns/monitor-4"}
+	for i, info := range infos {
+		if info.Key != expectedKeys[i] {
+			t.Errorf("unexpected key. Got: %s, expected: %s", info.Key, expectedKeys[i])
+		}
+		if info.State != StateStarting && info.State != StateRunning {
+			t.Errorf("[%s] unexpected state: %s", info.Key, info.State)
+		}
+	}
+	if infos[0].Generation != 101 {
+		t.Errorf("expected the replacement runner, got generation %d", infos[0].Generation)
+	}
+
+	// All runners stop with the manager
+	close(stop)
+	waitDone := make(chan struct{})
+	go func() {
+		manager.Wait(time.Minute)
+		close(waitDone)
+	}()
+	select {
+	case <-waitDone:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("runners did not stop")
+	}
+	for _, info := range manager.List() {
+		if info.State != StateStopped {
+			t.Errorf("[%s] unexpected state: %s", info.Key, info.State)
+		}
+	}
+	// Runners added during shutdown never start
+	late := newTestRunner(1)
+	manager.Replace("ns/late", late)
+	waitForDone(t, late)
+}
+
+func TestManager_WaitTimeout(t *testing.T) {
+	stop := make(chan struct{})
+	close(stop)
+	manager := NewManager()
+	manager.stop = stop
+	// A runner that never finishes, like one stuck in a request
+	manager.runners["ns/stuck"] = newTestRunner(1)
+
+	start := time.Now()
+	if manager.Wait(50 * time.Millisecond) {
+		t.Errorf("expected the wait to time out")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("wait took too long: %s", elapsed)
+	}
+}
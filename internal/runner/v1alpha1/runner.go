@@ -1,41 +1,240 @@
 package v1alpha1
 
 import (
+	"context"
+	"errors"
 	monitoringraisingthefloororgv1alpha1 "github.com/oregondesignservices/monitoring-controller/api/v1alpha1"
+	"github.com/oregondesignservices/monitoring-controller/internal/conf"
+	"github.com/oregondesignservices/monitoring-controller/internal/httpclient"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sync"
 	"time"
 )
 
+var runnerLogger = logf.Log.WithName("httpmonitor-runner")
+
+type State string
+
+const (
+	StateCreated  State = "created"  // not started yet
+	StateStarting State = "starting" // started, not scheduling runs yet
+	StateRunning  State = "running"  // waiting for the next period, or executing the monitor
+	StateStopping State = "stopping" // stopped, but may still be finishing a run
+	StateStopped  State = "stopped"  // done
+)
+
 type HttpMonitorRunner struct {
 	*monitoringraisingthefloororgv1alpha1.HttpMonitor
-	ticker *time.Ticker
-	closer chan bool
+	// Identifies the versions of referenced Secrets and ConfigMaps the runner was started with
+	DependencyVersion string
+
+	client      client.Client
+	reader      client.Reader
+	runState    monitoringraisingthefloororgv1alpha1.RunnerState
+	status      *statusWriter
+	gracePeriod time.Duration
+
+	lock     sync.Mutex
+	state    State
+	since    time.Time
+	stopOnce sync.Once
+	closer   chan struct{}
+	done     chan struct{}
 }
 
-func NewHttpMonitorRunner(m *monitoringraisingthefloororgv1alpha1.HttpMonitor) *HttpMonitorRunner {
-	return &HttpMonitorRunner{HttpMonitor: m}
+// The client writes the status. Referenced Secrets and ConfigMaps are read with reader.
+func NewHttpMonitorRunner(m *monitoringraisingthefloororgv1alpha1.HttpMonitor, c client.Client, reader client.Reader, dependencyVersion string) *HttpMonitorRunner {
+	return &HttpMonitorRunner{
+		HttpMonitor:       m,
+		DependencyVersion: dependencyVersion,
+		client:            c,
+		reader:            reader,
+		status:            newStatusWriter(c, conf.GlobalConfig.StatusUpdateInterval, &m.Status),
+		gracePeriod:       conf.GlobalConfig.ShutdownGracePeriod,
+		state:             StateCreated,
+		since:             time.Now(),
+		closer:            make(chan struct{}),
+		done:              make(chan struct{}),
+	}
 }
 
-func (h *HttpMonitorRunner) Start() {
-	if h.ticker != nil {
-		panic("tried to start an already started HttpMonitor")
+// Start implements manager.Runnable. Executes the monitor every period, or on its schedule, until stop is closed
+// or the runner is stopped.
+// After stop is closed, a run in progress gets the grace period to complete before it is cancelled. Stopping the
+// runner cancels a run in progress right away. Either way, the cleanup requests of the run still execute.
+func (h *HttpMonitorRunner) Start(stop <-chan struct{}) error {
+	h.lock.Lock()
+	if h.state == StateStopped {
+		// Stopped before it started, like when its monitor changed right away
+		h.lock.Unlock()
+		return nil
+	}
+	if h.state != StateCreated {
+		h.lock.Unlock()
+		return errors.New("tried to start an already started HttpMonitor")
 	}
+	h.setState(StateStarting)
+	h.lock.Unlock()
 
-	h.ticker = time.NewTicker(h.Spec.Period.Duration)
-	h.closer = make(chan bool)
+	defer func() {
+		httpclient.Release(&h.runState.Client)
+		h.transition(StateStopped)
+		close(h.done)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	go func() {
-		for {
+		select {
+		case <-h.closer:
+			cancel()
+		case <-stop:
+			h.transition(StateStopping)
 			select {
-			case <-h.ticker.C:
-				h.Execute()
+			case <-time.After(h.gracePeriod):
+				runnerLogger.Info("grace period is over, cancelling the current run", "namespace", h.Namespace, "name", h.Name)
+				cancel()
 			case <-h.closer:
-				return
+				cancel()
+			case <-ctx.Done():
 			}
 		}
 	}()
+
+	if !h.transition(StateRunning) {
+		return nil
+	}
+	now := time.Now()
+	if _, err := h.NextRun(now, now); err != nil {
+		runnerLogger.Error(err, "invalid schedule, not running the monitor", "namespace", h.Namespace, "name", h.Name)
+		h.Status.SetCondition(monitoringraisingthefloororgv1alpha1.HttpMonitorConditionReady, corev1.ConditionFalse, "InvalidSchedule", err.Error())
+		h.writeStatus()
+		return nil
+	}
+	delay, err := h.InitialDelay(now)
+	if err != nil {
+		runnerLogger.Error(err, "invalid initial delay, running without jitter", "namespace", h.Namespace, "name", h.Name)
+	}
+	scheduled := now.Add(delay)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			// The timer may win the race against a stop
+			if h.State() != StateRunning {
+				return nil
+			}
+			h.run(ctx)
+			scheduled, err = h.NextRun(scheduled, time.Now())
+			if err != nil {
+				runnerLogger.Error(err, "invalid schedule, not running the monitor", "namespace", h.Namespace, "name", h.Name)
+				return nil
+			}
+			timer.Reset(time.Until(scheduled))
+		case <-h.closer:
+			return nil
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// Execute the monitor once and record the results in its status. Runs outside of the monitor's windows are
+// recorded as skipped. A cancelled run is incomplete, so it is not recorded.
+func (h *HttpMonitorRunner) run(ctx context.Context) {
+	now := time.Now()
+	reason, err := h.SkipReason(now)
+	if err != nil {
+		// Running anyway could interfere with what a blackout window protects, so the run fails instead
+		result := &monitoringraisingthefloororgv1alpha1.ExecutionResult{StartTime: now, Error: err.Error()}
+		h.record(result)
+		return
+	}
+	if reason != "" {
+		runnerLogger.Info("skipping run", "namespace", h.Namespace, "name", h.Name, "reason", reason)
+		h.Status.RecordSkipped(h.Generation, now, reason)
+		monitoringraisingthefloororgv1alpha1.HandleRunMetrics(h.HttpMonitor, monitoringraisingthefloororgv1alpha1.RunResultSkipped)
+		h.writeStatus()
+		return
+	}
+
+	result := h.Execute(ctx, h.reader, &h.runState)
+	if ctx.Err() != nil {
+		runnerLogger.Info("run was cancelled", "namespace", h.Namespace, "name", h.Name)
+		return
+	}
+	h.record(result)
 }
 
+func (h *HttpMonitorRunner) record(result *monitoringraisingthefloororgv1alpha1.ExecutionResult) {
+	h.Status.RecordExecution(h.Generation, result)
+	monitoringraisingthefloororgv1alpha1.HandleRunMetrics(h.HttpMonitor, result.Result())
+	h.writeStatus()
+}
+
+func (h *HttpMonitorRunner) writeStatus() {
+	err := h.status.Write(h.HttpMonitor)
+	if err != nil {
+		runnerLogger.Error(err, "failed to update status", "namespace", h.Namespace, "name", h.Name)
+	}
+}
+
+// Stop the runner and cancel its run in progress. Does not wait for the run to finish, see Done.
+// Safe to call more than once.
 func (h *HttpMonitorRunner) Stop() {
-	// Stop does not close the channel, so the closer channel handles that.
-	h.closer <- true
-	h.ticker.Stop()
+	h.stopOnce.Do(func() {
+		h.lock.Lock()
+		defer h.lock.Unlock()
+		if h.state == StateCreated {
+			// Never started, so there is no goroutine to wait for
+			h.setState(StateStopped)
+			close(h.done)
+		} else {
+			h.setState(StateStopping)
+		}
+		close(h.closer)
+	})
+}
+
+// Closed once the runner is stopped and no longer executing the monitor
+func (h *HttpMonitorRunner) Done() <-chan struct{} {
+	return h.done
+}
+
+func (h *HttpMonitorRunner) State() State {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.state
+}
+
+// Move to a new state. Returns false if the runner is already stopping or stopped, since that is final.
+func (h *HttpMonitorRunner) transition(state State) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.state == StateStopped || (h.state == StateStopping && state != StateStopped) {
+		return false
+	}
+	h.setState(state)
+	return true
+}
+
+// Must hold the lock
+func (h *HttpMonitorRunner) setState(state State) {
+	h.state = state
+	h.since = time.Now()
+}
+
+func (h *HttpMonitorRunner) info(key string) RunnerInfo {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return RunnerInfo{
+		Key:               key,
+		State:             h.state,
+		Since:             h.since,
+		Generation:        h.Generation,
+		DependencyVersion: h.DependencyVersion,
+	}
 }
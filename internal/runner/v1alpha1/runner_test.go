@@ -0,0 +1,172 @@
+package v1alpha1
+
+import (
+	"fmt"
+	monitoringraisingthefloororgv1alpha1 "github.com/oregondesignservices/monitoring-controller/api/v1alpha1"
+	"github.com/oregondesignservices/monitoring-controller/internal/httpclient"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpMonitorRunner_Shutdown(t *testing.T) {
+	httpclient.Initialize(5 * time.Second)
+
+	var mutex sync.Mutex
+	var visited []string
+	started := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		visited = append(visited, r.URL.Path)
+		mutex.Unlock()
+		if r.URL.Path == "/slow" {
+			started <- struct{}{}
+			select {
+			case <-time.After(300 * time.Millisecond):
+			case <-r.Context().Done():
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	newRequest := func(path string) monitoringraisingthefloororgv1alpha1.HttpRequest {
+		return monitoringraisingthefloororgv1alpha1.HttpRequest{
+			Name:                  path,
+			Method:                "GET",
+			Url:                   server.URL + path,
+			ExpectedResponseCodes: []int{200},
+		}
+	}
+	monitor := &monitoringraisingthefloororgv1alpha1.HttpMonitor{
+		Spec: monitoringraisingthefloororgv1alpha1.HttpMonitorSpec{
+			Period:     &metav1.Duration{Duration: 10 * time.Millisecond},
+			RunTimeout: "5s",
+			Requests:   []monitoringraisingthefloororgv1alpha1.HttpRequest{newRequest("/slow"), newRequest("/next")},
+			Cleanup:    []monitoringraisingthefloororgv1alpha1.HttpRequest{newRequest("/cleanup")},
+		},
+	}
+
+	tests := []struct {
+		TestName    string
+		GracePeriod time.Duration
+		StopRunner  bool
+		Expected    []string
+	}{
+		{"run-completes", time.Second, false, []string{"/slow", "/next", "/cleanup"}},
+		{"run-is-cancelled", 50 * time.Millisecond, false, []string{"/slow", "/cleanup"}},
+		// Stopping a runner, like when its monitor is deleted, does not wait for the grace period
+		{"runner-stopped", time.Minute, true, []string{"/slow", "/cleanup"}},
+	}
+
+	for _, testdata := range tests {
+		visited = nil
+		runner := NewHttpMonitorRunner(monitor.DeepCopy(), nil, nil, "")
+		runner.gracePeriod = testdata.GracePeriod
+
+		stop := make(chan struct{})
+		go func() { _ = runner.Start(stop) }()
+
+		<-started
+		if testdata.StopRunner {
+			runner.Stop()
+		}
+		close(stop)
+		waitForDone(t, runner)
+
+		mutex.Lock()
+		if fmt.Sprint(visited) != fmt.Sprint(testdata.Expected) {
+			t.Errorf("[%s] unexpected requests. Got: %v, expected: %v", testdata.TestName, visited, testdata.Expected)
+		}
+		mutex.Unlock()
+	}
+}
+
+func TestHttpMonitorRunner_RunImmediately(t *testing.T) {
+	httpclient.Initialize(5 * time.Second)
+
+	visited := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		visited <- struct{}{}
+	}))
+	defer server.Close()
+
+	runImmediately := true
+	monitor := &monitoringraisingthefloororgv1alpha1.HttpMonitor{
+		Spec: monitoringraisingthefloororgv1alpha1.HttpMonitorSpec{
+			Period:         &metav1.Duration{Duration: time.Hour},
+			RunImmediately: &runImmediately,
+			Requests: []monitoringraisingthefloororgv1alpha1.HttpRequest{
+				{Name: "check", Method: "GET", Url: server.URL, ExpectedResponseCodes: []int{200}},
+			},
+		},
+	}
+	runner := NewHttpMonitorRunner(monitor, nil, nil, "")
+	stop := make(chan struct{})
+	go func() { _ = runner.Start(stop) }()
+	defer func() {
+		close(stop)
+		waitForDone(t, runner)
+	}()
+
+	select {
+	case <-visited:
+	case <-time.After(5 * time.Second):
+		t.Errorf("expected the monitor to run without waiting for the period")
+	}
+}
+
+func TestHttpMonitorRunner_BlackoutWindow(t *testing.T) {
+	httpclient.Initialize(5 * time.Second)
+
+	var visited int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&visited, 1)
+	}))
+	defer server.Close()
+
+	monitor := &monitoringraisingthefloororgv1alpha1.HttpMonitor{
+		Spec: monitoringraisingthefloororgv1alpha1.HttpMonitorSpec{
+			Period:          &metav1.Duration{Duration: 10 * time.Millisecond},
+			BlackoutWindows: []monitoringraisingthefloororgv1alpha1.TimeWindow{{Name: "all day", Start: "00:00", End: "00:00"}},
+			Requests: []monitoringraisingthefloororgv1alpha1.HttpRequest{
+				{Name: "check", Method: "GET", Url: server.URL, ExpectedResponseCodes: []int{200}},
+			},
+		},
+	}
+	runner := NewHttpMonitorRunner(monitor, nil, nil, "")
+	stop := make(chan struct{})
+	go func() { _ = runner.Start(stop) }()
+	time.Sleep(100 * time.Millisecond)
+	close(stop)
+	waitForDone(t, runner)
+
+	if atomic.LoadInt32(&visited) != 0 {
+		t.Errorf("expected no requests during the blackout window")
+	}
+	if runner.Status.LastResult != monitoringraisingthefloororgv1alpha1.RunResultSkipped || runner.Status.LastSkipped == nil {
+		t.Errorf("expected the runs to be recorded as skipped, got: %s", runner.Status.LastResult)
+	}
+	if runner.Status.SkipReason != "in blackout window 'all day'" {
+		t.Errorf("unexpected skip reason: %s", runner.Status.SkipReason)
+	}
+}
+
+func TestHttpMonitorRunner_InvalidSchedule(t *testing.T) {
+	monitor := &monitoringraisingthefloororgv1alpha1.HttpMonitor{
+		Spec: monitoringraisingthefloororgv1alpha1.HttpMonitorSpec{Schedule: "every minute"},
+	}
+	runner := NewHttpMonitorRunner(monitor, nil, nil, "")
+	go func() { _ = runner.Start(make(chan struct{})) }()
+
+	// The runner gives up on its own
+	waitForDone(t, runner)
+	condition := runner.Status.GetCondition(monitoringraisingthefloororgv1alpha1.HttpMonitorConditionReady)
+	if condition == nil || condition.Reason != "InvalidSchedule" {
+		t.Errorf("unexpected ready condition: %v", condition)
+	}
+}
@@ -0,0 +1,60 @@
+package v1alpha1
+
+import (
+	"context"
+	monitoringraisingthefloororgv1alpha1 "github.com/oregondesignservices/monitoring-controller/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
+)
+
+// Writes HttpMonitor status through the status subresource. Writes are rate-limited so short
+// periods don't flood the API server, but a change in health is always written right away.
+type statusWriter struct {
+	client      client.Client
+	minInterval time.Duration
+
+	lastWrite   time.Time
+	lastWritten *monitoringraisingthefloororgv1alpha1.HttpMonitorStatus
+}
+
+func newStatusWriter(c client.Client, minInterval time.Duration, current *monitoringraisingthefloororgv1alpha1.HttpMonitorStatus) *statusWriter {
+	return &statusWriter{
+		client:      c,
+		minInterval: minInterval,
+		lastWritten: current.DeepCopy(),
+	}
+}
+
+func (w *statusWriter) shouldWrite(status *monitoringraisingthefloororgv1alpha1.HttpMonitorStatus, now time.Time) bool {
+	if w.lastWrite.IsZero() {
+		return true
+	}
+	if status.IsHealthy() != w.lastWritten.IsHealthy() {
+		return true
+	}
+	return now.Sub(w.lastWrite) >= w.minInterval
+}
+
+// Write the status of the monitor, unless rate-limited
+func (w *statusWriter) Write(monitor *monitoringraisingthefloororgv1alpha1.HttpMonitor) error {
+	if w.client == nil {
+		return nil
+	}
+	now := time.Now()
+	if !w.shouldWrite(&monitor.Status, now) {
+		return nil
+	}
+
+	// Only send the status. The runner's copy of the spec may include global variables.
+	before := monitor.DeepCopy()
+	before.Status = *w.lastWritten
+	after := monitor.DeepCopy()
+
+	err := w.client.Status().Patch(context.Background(), after, client.MergeFrom(before))
+	if err != nil {
+		return err
+	}
+	w.lastWrite = now
+	w.lastWritten = monitor.Status.DeepCopy()
+	return nil
+}
@@ -20,6 +20,7 @@ import (
 	monitoringraisingthefloororgv1alpha1 "github.com/oregondesignservices/monitoring-controller/api/v1alpha1"
 	"github.com/oregondesignservices/monitoring-controller/controllers"
 	"github.com/oregondesignservices/monitoring-controller/internal/conf"
+	runnerv1alpha1 "github.com/oregondesignservices/monitoring-controller/internal/runner/v1alpha1"
 	"github.com/urfave/cli/v2"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -77,18 +78,43 @@ func run(c *cli.Context) error {
 		os.Exit(1)
 	}
 
+	// Runners are bound to the manager, so they stop on shutdown or when leadership is lost
+	runners := runnerv1alpha1.NewManager()
+	if err = mgr.Add(runners); err != nil {
+		setupLog.Error(err, "unable to add runners to the manager")
+		os.Exit(1)
+	}
+
 	if err = (&controllers.HttpMonitorReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("HttpMonitor"),
-		Scheme: mgr.GetScheme(),
+		Client:                  mgr.GetClient(),
+		Log:                     ctrl.Log.WithName("controllers").WithName("HttpMonitor"),
+		Scheme:                  mgr.GetScheme(),
+		Runners:                 runners,
+		APIReader:               mgr.GetAPIReader(),
+		MaxConcurrentReconciles: conf.GlobalConfig.MaxConcurrentReconciles,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "HttpMonitor")
 		os.Exit(1)
 	}
+	if conf.GlobalConfig.EnableWebhooks {
+		if err = (&monitoringraisingthefloororgv1alpha1.HttpMonitor{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "HttpMonitor")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	err = mgr.Start(ctrl.SetupSignalHandler())
+
+	// The manager does not wait for runnables. Let runs in progress and their cleanup finish.
+	shutdownTimeout := conf.GlobalConfig.ShutdownGracePeriod + conf.GlobalConfig.CleanupTimeout
+	setupLog.Info("waiting for runners to stop", "timeout", shutdownTimeout)
+	if !runners.Wait(shutdownTimeout) {
+		setupLog.Info("runners did not stop in time, exiting anyway")
+	}
+
+	if err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}